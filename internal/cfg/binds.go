@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -28,6 +29,7 @@ var actionNames = map[string]int{
 // Keybind parsing regexes
 var keyRegexp = regexp.MustCompile(`^code(\d+)$`)
 var numRegexp = regexp.MustCompile(`\((\d+)\)$`)
+var nameRegexp = regexp.MustCompile(`\(([a-z_]+)\)$`)
 
 // Action represents a single keybind action.
 type Action struct {
@@ -36,6 +38,10 @@ type Action struct {
 
 	// Extra detail for the action (e.g. instance number.)
 	Extra *int
+
+	// The name of the alternate resolution for the action, if it was given
+	// by name. It is resolved to Extra when the profile is validated.
+	Name string
 }
 
 // ActionList contains a list of actions to perform when a keybind is pressed.
@@ -82,8 +88,15 @@ func (a *ActionList) UnmarshalTOML(value any) error {
 	uniqueGame := make(map[Action]bool)
 	for _, actionStr := range actions {
 		if typ, ok := actionNames[actionStr]; ok {
-			a.IngameActions = append(a.IngameActions, Action{typ, nil})
-			uniqueGame[Action{typ, nil}] = true
+			a.IngameActions = append(a.IngameActions, Action{typ, nil, ""})
+			uniqueGame[Action{typ, nil, ""}] = true
+		} else if loc := nameRegexp.FindStringIndex(actionStr); loc != nil {
+			name := actionStr[loc[0]+1 : loc[1]-1]
+			if typ := actionStr[:loc[0]]; actionNames[typ] != ActionIngameRes {
+				return fmt.Errorf("action %q cannot have name", actionStr)
+			}
+			a.IngameActions = append(a.IngameActions, Action{ActionIngameRes, nil, name})
+			uniqueGame[Action{ActionIngameRes, nil, name}] = true
 		} else {
 			loc := numRegexp.FindStringIndex(actionStr)
 			if loc == nil {
@@ -98,8 +111,8 @@ func (a *ActionList) UnmarshalTOML(value any) error {
 			typ := actionStr[:loc[0]]
 			if typ, ok := actionNames[typ]; ok {
 				if typ == ActionIngameRes {
-					a.IngameActions = append(a.IngameActions, Action{typ, &num})
-					uniqueGame[Action{typ, &num}] = true
+					a.IngameActions = append(a.IngameActions, Action{typ, &num, ""})
+					uniqueGame[Action{typ, &num, ""}] = true
 				} else {
 					return fmt.Errorf("action %q cannot have number", actionStr)
 				}
@@ -125,17 +138,35 @@ func (b *Bind) UnmarshalTOML(value any) error {
 	if !ok {
 		return errors.New("bind value was not a string")
 	}
+	return b.parse(str, nil)
+}
+
+// parse parses the string representation of a Bind. If a keysym mapping is
+// given, key and modifier names are resolved using the current keyboard
+// layout where possible.
+func (b *Bind) parse(str string, keysyms map[xproto.Keysym]xproto.Keycode) error {
 	if str == "" {
 		return nil
 	}
+	lookup := func(names map[string]xproto.Keycode, name string) (xproto.Keycode, bool) {
+		if _, ok := names[name]; !ok {
+			return 0, false
+		}
+		if sym, ok := x11.Keysyms[name]; ok && keysyms != nil {
+			if code, ok := keysyms[sym]; ok {
+				return code, true
+			}
+		}
+		return names[name], true
+	}
 	for _, split := range strings.Split(str, "-") {
 		split = strings.ToLower(split)
-		if key, ok := x11.Keycodes[split]; ok {
+		if key, ok := lookup(x11.Keycodes, split); ok {
 			if b.Key != nil {
 				return errors.New("more than one key")
 			}
 			b.Key = &key
-		} else if mod, ok := x11.Modifiers[split]; ok {
+		} else if mod, ok := lookup(x11.Modifiers, split); ok {
 			if b.ModCount == 4 {
 				return errors.New("too many modifiers (max of 4)")
 			}
@@ -167,11 +198,22 @@ func (b *Bind) UnmarshalTOML(value any) error {
 	return nil
 }
 
+// Index returns the index of the alternate resolution with the given name, or
+// -1 if there is none.
+func (a AltRes) Index(name string) int {
+	for idx, res := range a {
+		if res.Name == name {
+			return idx
+		}
+	}
+	return -1
+}
+
 // UnmarshalTOML implements toml.Unmarshaler.
 func (a *AltRes) UnmarshalTOML(value any) error {
 	switch value := value.(type) {
 	case string:
-		rect, err := parseRectangle(value)
+		rect, err := parseNamedRectangle(value)
 		if err != nil {
 			return err
 		}
@@ -183,7 +225,7 @@ func (a *AltRes) UnmarshalTOML(value any) error {
 				return fmt.Errorf("parse alt_res %d: non-string value", i)
 			}
 
-			rect, err := parseRectangle(res)
+			rect, err := parseNamedRectangle(res)
 			if err != nil {
 				return fmt.Errorf("parse alt_res %d: %w", i, err)
 			}
@@ -254,3 +296,53 @@ func (k *Keybinds) UnmarshalTOML(value any) error {
 	}
 	return nil
 }
+
+// Conflicts returns the pairs of keybinds which are triggered by the exact
+// same input (e.g. "a" and "code38".)
+func (k Keybinds) Conflicts() [][2]string {
+	seen := make(map[string]string, len(k))
+	var conflicts [][2]string
+	for bind := range k {
+		bind := bind
+		input := bind.input()
+		if other, ok := seen[input]; ok {
+			conflicts = append(conflicts, [2]string{other, bind.String()})
+		} else {
+			seen[input] = bind.String()
+		}
+	}
+	return conflicts
+}
+
+// input returns a string which uniquely identifies the input that triggers
+// the keybind, regardless of how it was written.
+func (b *Bind) input() string {
+	mods := make([]int, b.ModCount)
+	for i, mod := range b.Mods[:b.ModCount] {
+		mods[i] = int(mod)
+	}
+	sort.Ints(mods)
+	input := fmt.Sprint(mods)
+	if b.Key != nil {
+		input += fmt.Sprintf(" key %d", *b.Key)
+	}
+	if b.Button != nil {
+		input += fmt.Sprintf(" button %d", *b.Button)
+	}
+	return input
+}
+
+// ResolveLayout returns a copy of the keybinds with all key and modifier names
+// resolved using the given keysym mapping, so that they match the user's
+// keyboard layout rather than a US QWERTY layout.
+func (k Keybinds) ResolveLayout(keysyms map[xproto.Keysym]xproto.Keycode) (Keybinds, error) {
+	resolved := make(Keybinds, len(k))
+	for bind, actions := range k {
+		var newBind Bind
+		if err := newBind.parse(bind.str, keysyms); err != nil {
+			return nil, fmt.Errorf("resolve bind %q: %w", bind.str, err)
+		}
+		resolved[newBind] = actions
+	}
+	return resolved, nil
+}
@@ -6,12 +6,50 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/tesselslate/resetti/internal/log"
 	"github.com/tesselslate/resetti/internal/res"
+	"golang.org/x/exp/slices"
 )
 
+// defaultArchiveMinTime is the value of Archive.MinTime used when a profile
+// does not set it, so that older profiles do not archive every world.
+const defaultArchiveMinTime = 600
+
+// Archive contains the settings for archiving played worlds.
+type Archive struct {
+	Dir     string `toml:"dir"`      // Directory to archive worlds in (disabled if empty)
+	MinTime int    `toml:"min_time"` // Minimum seconds played before a world is archived
+}
+
+// Counter contains the settings for the reset counter.
+type Counter struct {
+	File      string `toml:"file"`       // File to store the reset count in (if any)
+	DailyFile string `toml:"daily_file"` // File to store today's reset count in (if any)
+	Url       string `toml:"url"`        // URL to send the reset counts to (if any)
+	Rollover  string `toml:"rollover"`   // Local time (HH:MM) at which the daily count resets
+
+	// SpeedRunIGT attempts file to keep in sync, relative to the instance's
+	// .minecraft directory (if any)
+	AttemptsFile string `toml:"attempts_file"`
+}
+
+// Diagnostics contains the settings for measuring the X server's latency and
+// clock.
+type Diagnostics struct {
+	Interval   int `toml:"interval"`    // Seconds between measurements (disabled if 0)
+	MaxLatency int `toml:"max_latency"` // Round trip time (ms) above which to warn (never if 0)
+	MaxDrift   int `toml:"max_drift"`   // Time offset drift (ms) above which to warn (never if 0)
+
+	// Measure the time offset again when it drifts or the system resumes
+	// from suspend
+	Recalibrate bool `toml:"recalibrate"`
+}
+
 // Hooks contains various commands to run whenever the user performs certain
 // actions.
 type Hooks struct {
@@ -20,6 +58,17 @@ type Hooks struct {
 	NormalRes   NormalResHook `toml:"normal_res"`   // Command to run on normal resolution
 	FocusLost   string        `toml:"focus_lost"`   // Command to run when instance loses focus
 	FocusGained string        `toml:"focus_gained"` // Command to run when instance gains focus
+	Exit        string        `toml:"exit"`         // Command to run when resetti exits
+	Crash       string        `toml:"crash"`        // Command to run when the instance crashes
+}
+
+// Instance contains settings used to find the user's Minecraft instance.
+type Instance struct {
+	Class   Regexp `toml:"class"`    // Window class pattern (contains "Minecraft" if unset)
+	Title   Regexp `toml:"title"`    // Window title pattern (any if unset)
+	Window  uint32 `toml:"window"`   // Exact window ID (any if unset)
+	PidFile string `toml:"pid_file"` // File containing the instance's PID (any if unset)
+	Version int    `toml:"version"`  // Minor game version, e.g. 16 for 1.16 (from the title if unset)
 }
 
 // Keybinds contains the user's keybindings.
@@ -27,18 +76,102 @@ type Keybinds map[Bind]ActionList
 
 // Profile contains an entire configuration profile.
 type Profile struct {
-	PollRate  int        `toml:"poll_rate"` // Polling rate for input handling
-	NormalRes *Rectangle `toml:"play_res"`  // Normal resolution
-	AltRes    AltRes     `toml:"alt_res"`   // Alternate ingame resolution
+	PollRate    int        `toml:"poll_rate"`    // Polling rate for input handling
+	LayoutBinds bool       `toml:"layout_binds"` // Resolve keybinds with the keyboard layout
+	Unredirect  bool       `toml:"unredirect"`   // Bypass the compositor while playing
+	NormalRes   *Rectangle `toml:"play_res"`     // Normal resolution
+	AltRes      AltRes     `toml:"alt_res"`      // Alternate ingame resolution
+
+	Instance  Instance  `toml:"instance"`
+	Hooks     Hooks     `toml:"hooks"`
+	Keybinds  Keybinds  `toml:"keybinds"`
+	Remote    Remote    `toml:"remote"`
+	Sounds    Sounds    `toml:"sounds"`
+	LiveSplit LiveSplit `toml:"livesplit"`
+	Counter   Counter   `toml:"counter"`
+	Watchdog  Watchdog  `toml:"watchdog"`
+	Worlds    Worlds    `toml:"worlds"`
+	Archive   Archive   `toml:"archive"`
+
+	Diagnostics   Diagnostics   `toml:"diagnostics"`
+	Notifications Notifications `toml:"notifications"`
+	Log           Log           `toml:"log"`
+}
+
+// LiveSplit contains the settings for controlling a LiveSplit Server.
+type LiveSplit struct {
+	Address      string `toml:"address"`        // Address of the server (disabled if empty)
+	StartOnReset bool   `toml:"start_on_reset"` // Start the timer after every reset
+}
+
+// Log contains the settings for resetti's log.
+type Log struct {
+	Level   string `toml:"level"`    // Log level (info if unset)
+	Format  string `toml:"format"`   // Log format ("text", "json", or "logfmt")
+	MaxSize int    `toml:"max_size"` // Size in MiB at which the log is rotated (never if 0)
+	Backups int    `toml:"backups"`  // Number of rotated logs to keep (1 if unset)
+
+	// Log levels of specific modules (e.g. "ctl"), overriding Level.
+	Modules map[string]string `toml:"modules"`
+}
+
+// Notifications contains the settings for desktop notifications.
+type Notifications struct {
+	Enabled  bool `toml:"enabled"`  // Whether to show desktop notifications
+	Throttle int  `toml:"throttle"` // Minimum seconds between identical notifications
+}
+
+// Remote contains the settings for the remote control server.
+type Remote struct {
+	Address string `toml:"address"` // Address to listen on (disabled if empty)
+	Token   string `toml:"token"`   // Token required from clients
+
+	// Token for read-only clients, which can watch state and events but not
+	// perform actions (disabled if empty)
+	ReadToken string `toml:"read_token"`
+}
+
+// Sound is an audio cue to play.
+type Sound struct {
+	File   string  `toml:"file"`   // Path to the sound file (disabled if empty)
+	Volume float64 `toml:"volume"` // Volume from 0 to 1 (full volume if unset)
+}
+
+// Sounds contains the audio cues to play whenever the user performs certain
+// actions.
+type Sounds struct {
+	Reset       Sound `toml:"reset"`        // Sound to play on ingame reset
+	AltRes      Sound `toml:"alt_res"`      // Sound to play on alternate resolution
+	NormalRes   Sound `toml:"normal_res"`   // Sound to play on normal resolution
+	FocusLost   Sound `toml:"focus_lost"`   // Sound to play when instance loses focus
+	FocusGained Sound `toml:"focus_gained"` // Sound to play when instance gains focus
+}
 
-	Hooks    Hooks    `toml:"hooks"`
-	Keybinds Keybinds `toml:"keybinds"`
+// Watchdog contains the settings for the instance health watchdog.
+type Watchdog struct {
+	Timeout int    `toml:"timeout"` // Seconds before an instance is considered stuck (disabled if 0)
+	Action  string `toml:"action"`  // What to do with a stuck instance ("notify", "reset", or "sigcont")
+}
+
+// Worlds contains the settings for cleaning up old worlds.
+type Worlds struct {
+	Keep   int    `toml:"keep"`    // Number of newest worlds to keep (any if 0)
+	MaxAge int    `toml:"max_age"` // Minutes after which worlds are removed (never if 0)
+	MoveTo string `toml:"move_to"` // Directory to move old worlds to (deleted if empty)
+	Rate   int    `toml:"rate"`    // Maximum number of worlds to remove per minute
+}
+
+// Regexp is a regular expression.
+type Regexp struct {
+	*regexp.Regexp
 }
 
 // Rectangle is a rectangle. That's it.
 type Rectangle struct {
 	X, Y int32
 	W, H uint32
+
+	Name string // Name of the rectangle (alternate resolutions only.)
 }
 
 // GetDirectory returns the path to the user's configuration directory.
@@ -62,11 +195,19 @@ func GetProfile(name string) (Profile, error) {
 	if err != nil {
 		return Profile{}, fmt.Errorf("read config file: %w", err)
 	}
-	profile := Profile{}
-	if err = toml.Unmarshal(file, &profile); err != nil {
+	return parseProfile(file)
+}
+
+// parseProfile parses and validates a configuration profile. Settings which
+// should not default to their zero value when absent are filled in first.
+func parseProfile(file []byte) (Profile, error) {
+	profile := Profile{
+		Archive: Archive{MinTime: defaultArchiveMinTime},
+	}
+	if err := toml.Unmarshal(file, &profile); err != nil {
 		return Profile{}, fmt.Errorf("parse config file: %w", err)
 	}
-	if err = validateProfile(&profile); err != nil {
+	if err := validateProfile(&profile); err != nil {
 		return Profile{}, fmt.Errorf("validate config: %w", err)
 	}
 	return profile, nil
@@ -123,12 +264,141 @@ func validateProfile(conf *Profile) error {
 			}
 		}
 	}
+	names := make(map[string]bool)
+	for _, res := range conf.AltRes {
+		if res.Name == "" {
+			continue
+		}
+		if names[res.Name] {
+			return fmt.Errorf("duplicate alternate resolution name %q", res.Name)
+		}
+		names[res.Name] = true
+	}
+	for bind, actions := range conf.Keybinds {
+		for i, action := range actions.IngameActions {
+			if action.Name == "" {
+				continue
+			}
+			idx := conf.AltRes.Index(action.Name)
+			if idx == -1 {
+				return fmt.Errorf("keybind %q uses unknown resolution %q", bind.String(), action.Name)
+			}
+			actions.IngameActions[i].Extra = &idx
+		}
+	}
 	alt := conf.AltRes != nil
 	normal := conf.NormalRes != nil
 	if alt && !normal {
 		return errors.New("need both alternate and playing resolution")
 	}
 
+	// Check instance settings.
+	if conf.Instance.Version != 0 && conf.Instance.Version < 14 {
+		return errors.New("only 1.14 and newer are currently supported")
+	}
+
+	// Check sound settings.
+	sounds := []Sound{
+		conf.Sounds.Reset,
+		conf.Sounds.AltRes,
+		conf.Sounds.NormalRes,
+		conf.Sounds.FocusLost,
+		conf.Sounds.FocusGained,
+	}
+	for _, sound := range sounds {
+		if sound.Volume < 0 || sound.Volume > 1 {
+			return fmt.Errorf("invalid volume %.2f for sound %q", sound.Volume, sound.File)
+		}
+	}
+
+	// Check notification settings.
+	if conf.Notifications.Throttle < 0 {
+		return errors.New("invalid notification throttle")
+	}
+
+	// Check reset counter settings.
+	if conf.Counter.Url != "" {
+		if !strings.HasPrefix(conf.Counter.Url, "http://") && !strings.HasPrefix(conf.Counter.Url, "https://") {
+			return fmt.Errorf("invalid counter url %q", conf.Counter.Url)
+		}
+	}
+	if conf.Counter.Rollover != "" {
+		if _, err := time.Parse("15:04", conf.Counter.Rollover); err != nil {
+			return fmt.Errorf("invalid counter rollover time %q", conf.Counter.Rollover)
+		}
+	}
+
+	// Check watchdog settings.
+	if conf.Watchdog.Timeout < 0 {
+		return errors.New("invalid watchdog timeout")
+	}
+	switch conf.Watchdog.Action {
+	case "", "notify", "reset", "sigcont":
+	default:
+		return fmt.Errorf("invalid watchdog action %q", conf.Watchdog.Action)
+	}
+
+	// Check world cleaner settings.
+	if conf.Worlds.Keep < 0 || conf.Worlds.MaxAge < 0 {
+		return errors.New("invalid world retention")
+	}
+	if (conf.Worlds.Keep > 0 || conf.Worlds.MaxAge > 0) && conf.Worlds.Rate <= 0 {
+		return errors.New("invalid world removal rate")
+	}
+	if conf.Worlds.MoveTo != "" {
+		stat, err := os.Stat(conf.Worlds.MoveTo)
+		if err != nil || !stat.IsDir() {
+			return fmt.Errorf("world destination %q is not a directory", conf.Worlds.MoveTo)
+		}
+	}
+
+	// Check archive settings.
+	if conf.Archive.MinTime < 0 {
+		return errors.New("invalid archive minimum time")
+	}
+
+	// Check diagnostics settings.
+	if conf.Diagnostics.Interval < 0 {
+		return errors.New("invalid diagnostics interval")
+	}
+	if conf.Diagnostics.MaxLatency < 0 || conf.Diagnostics.MaxDrift < 0 {
+		return errors.New("invalid diagnostics threshold")
+	}
+
+	// Check log settings.
+	if conf.Log.Level != "" {
+		if _, err := log.ParseLevel(conf.Log.Level); err != nil {
+			return err
+		}
+	}
+	switch conf.Log.Format {
+	case "", "text", "json", "logfmt":
+	default:
+		return fmt.Errorf("invalid log format %q", conf.Log.Format)
+	}
+	if conf.Log.MaxSize < 0 {
+		return errors.New("invalid log size")
+	}
+	if conf.Log.Backups < 0 {
+		return errors.New("invalid log backup count")
+	}
+	for module, level := range conf.Log.Modules {
+		if !slices.Contains(log.Modules, module) {
+			return fmt.Errorf("unknown log module %q", module)
+		}
+		if _, err := log.ParseLevel(level); err != nil {
+			return fmt.Errorf("log module %s: %w", module, err)
+		}
+	}
+
+	// Check remote control settings.
+	if conf.Remote.Address != "" && conf.Remote.Token == "" {
+		return errors.New("remote control server needs a token")
+	}
+	if conf.Remote.ReadToken != "" && conf.Remote.ReadToken == conf.Remote.Token {
+		return errors.New("remote control read-only token must differ from token")
+	}
+
 	return nil
 }
 
@@ -145,6 +415,21 @@ func parseRectangle(raw string) (Rectangle, error) {
 	return r, nil
 }
 
+// parseNamedRectangle attempts to parse the string representation of a
+// Rectangle which may be prefixed with a name (e.g. "eye:60x1080+930,0".)
+func parseNamedRectangle(raw string) (Rectangle, error) {
+	name, rest, ok := strings.Cut(raw, ":")
+	if !ok {
+		return parseRectangle(raw)
+	}
+	if !nameRegexp.MatchString("(" + name + ")") {
+		return Rectangle{}, fmt.Errorf("invalid resolution name %q", name)
+	}
+	r, err := parseRectangle(rest)
+	r.Name = name
+	return r, err
+}
+
 // validateRectangle ensures the rectangle has a size.
 func validateRectangle(r *Rectangle) bool {
 	return r == nil || r.W > 0 && r.H > 0
@@ -163,3 +448,13 @@ func (r *Rectangle) UnmarshalTOML(value any) error {
 	*r = rect
 	return nil
 }
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (r *Regexp) UnmarshalText(text []byte) error {
+	re, err := regexp.Compile(string(text))
+	if err != nil {
+		return fmt.Errorf("parse regex %q: %w", text, err)
+	}
+	r.Regexp = re
+	return nil
+}
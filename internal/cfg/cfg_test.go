@@ -0,0 +1,219 @@
+package cfg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/tesselslate/resetti/internal/res"
+)
+
+// defaultProfile returns the default profile, which is valid.
+func defaultProfile(t *testing.T) Profile {
+	t.Helper()
+	profile := Profile{}
+	if err := toml.Unmarshal(res.DefaultConfig, &profile); err != nil {
+		t.Fatalf("parse default profile: %s", err)
+	}
+	return profile
+}
+
+// bindTo replaces the keybinds of the profile with a single keybind which
+// performs the given actions.
+func bindTo(t *testing.T, conf *Profile, actions ...any) {
+	t.Helper()
+	bind := Bind{}
+	if err := bind.UnmarshalTOML("code10"); err != nil {
+		t.Fatalf("parse bind: %s", err)
+	}
+	list := ActionList{}
+	if err := list.UnmarshalTOML(actions); err != nil {
+		t.Fatalf("parse actions: %s", err)
+	}
+	conf.Keybinds = Keybinds{bind: list}
+}
+
+// namedRes returns the alternate resolutions with the given names.
+func namedRes(names ...string) AltRes {
+	var altRes AltRes
+	for _, name := range names {
+		altRes = append(altRes, Rectangle{0, 0, 100, 100, name})
+	}
+	return altRes
+}
+
+func TestValidateProfile(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(t *testing.T, conf *Profile)
+		ok     bool
+	}{
+		{
+			name:   "default",
+			modify: func(t *testing.T, conf *Profile) {},
+			ok:     true,
+		},
+		{
+			name: "named alt res",
+			modify: func(t *testing.T, conf *Profile) {
+				conf.AltRes = namedRes("eye", "wide")
+				bindTo(t, conf, "ingame_toggle_res(wide)")
+			},
+			ok: true,
+		},
+		{
+			name: "numbered alt res",
+			modify: func(t *testing.T, conf *Profile) {
+				conf.AltRes = namedRes("eye", "")
+				bindTo(t, conf, "ingame_toggle_res(2)")
+			},
+			ok: true,
+		},
+		{
+			name: "duplicate alt res name",
+			modify: func(t *testing.T, conf *Profile) {
+				conf.AltRes = namedRes("eye", "eye")
+			},
+		},
+		{
+			name: "unknown alt res name",
+			modify: func(t *testing.T, conf *Profile) {
+				conf.AltRes = namedRes("eye")
+				bindTo(t, conf, "ingame_toggle_res(wide)")
+			},
+		},
+		{
+			name: "alt res without play res",
+			modify: func(t *testing.T, conf *Profile) {
+				conf.NormalRes = nil
+			},
+		},
+		{
+			name: "read token",
+			modify: func(t *testing.T, conf *Profile) {
+				conf.Remote = Remote{"localhost:8080", "secret", "viewer"}
+			},
+			ok: true,
+		},
+		{
+			name: "read token same as token",
+			modify: func(t *testing.T, conf *Profile) {
+				conf.Remote = Remote{"localhost:8080", "secret", "secret"}
+			},
+		},
+		{
+			name: "remote without token",
+			modify: func(t *testing.T, conf *Profile) {
+				conf.Remote = Remote{"localhost:8080", "", "viewer"}
+			},
+		},
+		{
+			name: "instance version",
+			modify: func(t *testing.T, conf *Profile) {
+				conf.Instance = Instance{Window: 0x1234, Version: 16}
+			},
+			ok: true,
+		},
+		{
+			name: "old instance version",
+			modify: func(t *testing.T, conf *Profile) {
+				conf.Instance = Instance{PidFile: "/tmp/mc.pid", Version: 12}
+			},
+		},
+		{
+			name: "log module",
+			modify: func(t *testing.T, conf *Profile) {
+				conf.Log.Modules = map[string]string{"mc": "debug"}
+			},
+			ok: true,
+		},
+		{
+			name: "unknown log module",
+			modify: func(t *testing.T, conf *Profile) {
+				conf.Log.Modules = map[string]string{"wall": "debug"}
+			},
+		},
+		{
+			name: "invalid log module level",
+			modify: func(t *testing.T, conf *Profile) {
+				conf.Log.Modules = map[string]string{"mc": "loud"}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := defaultProfile(t)
+			tt.modify(t, &conf)
+			err := validateProfile(&conf)
+			if tt.ok && err != nil {
+				t.Errorf("got error %q, want none", err)
+			} else if !tt.ok && err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+}
+
+func TestValidateProfileResolvesNames(t *testing.T) {
+	conf := defaultProfile(t)
+	conf.AltRes = namedRes("eye", "wide")
+	bindTo(t, &conf, "ingame_toggle_res(wide)")
+	if err := validateProfile(&conf); err != nil {
+		t.Fatal(err)
+	}
+	for _, actions := range conf.Keybinds {
+		extra := actions.IngameActions[0].Extra
+		if extra == nil || *extra != 1 {
+			t.Errorf("got resolution %v, want 1", extra)
+		}
+	}
+}
+
+func TestParseNamedRectangle(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want Rectangle
+		ok   bool
+	}{
+		{"60x1080+930,0", Rectangle{930, 0, 60, 1080, ""}, true},
+		{"eye:60x1080+930,0", Rectangle{930, 0, 60, 1080, "eye"}, true},
+		{"thin_res:400x1080+-10,-20", Rectangle{-10, -20, 400, 1080, "thin_res"}, true},
+		{"Eye:60x1080+930,0", Rectangle{}, false},
+		{"eye2:60x1080+930,0", Rectangle{}, false},
+		{":60x1080+930,0", Rectangle{}, false},
+		{"eye:60x1080", Rectangle{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseNamedRectangle(tt.raw)
+		if tt.ok && err != nil {
+			t.Errorf("parseNamedRectangle(%q) got error %q", tt.raw, err)
+		} else if !tt.ok && err == nil {
+			t.Errorf("parseNamedRectangle(%q) got no error", tt.raw)
+		} else if tt.ok && got != tt.want {
+			t.Errorf("parseNamedRectangle(%q) = %+v, want %+v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestParseProfileArchiveMinTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		minTime string
+		want    int
+	}{
+		{"absent", "", defaultArchiveMinTime},
+		{"zero", "min_time = 0", 0},
+		{"set", "min_time = 30", 30},
+	}
+	for _, tt := range tests {
+		file := strings.Replace(string(res.DefaultConfig), "min_time = 600", tt.minTime, 1)
+		profile, err := parseProfile([]byte(file))
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if profile.Archive.MinTime != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, profile.Archive.MinTime, tt.want)
+		}
+	}
+}
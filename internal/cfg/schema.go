@@ -0,0 +1,98 @@
+package cfg
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// A schema is a JSON schema node.
+type schema map[string]any
+
+// schemaTypes contains the schemas of types which are decoded from a
+// different representation than their Go type would suggest.
+var schemaTypes = map[reflect.Type]schema{
+	reflect.TypeOf(Regexp{}):    {"type": "string", "format": "regex"},
+	reflect.TypeOf(Rectangle{}): rectangleSchema,
+	reflect.TypeOf(AltRes{}): {"oneOf": []schema{
+		namedRectangleSchema,
+		{"type": "array", "items": namedRectangleSchema},
+	}},
+	reflect.TypeOf(AltResHook{}):    stringsSchema,
+	reflect.TypeOf(NormalResHook{}): stringsSchema,
+	reflect.TypeOf(Keybinds{}): {
+		"type": "object",
+		"additionalProperties": schema{
+			"type":  "array",
+			"items": schema{"type": "string"},
+		},
+	},
+}
+
+// Schemas shared by several types.
+var (
+	rectangleSchema = schema{
+		"type":    "string",
+		"pattern": `^\d+x\d+\+-?\d+,-?\d+$`,
+	}
+	namedRectangleSchema = schema{
+		"type":    "string",
+		"pattern": `^([a-z_]+:)?\d+x\d+\+-?\d+,-?\d+$`,
+	}
+	stringsSchema = schema{"oneOf": []schema{
+		{"type": "string"},
+		{"type": "array", "items": schema{"type": "string"}},
+	}}
+)
+
+// Schema returns a JSON schema describing the structure of a profile, which
+// can be used by editors and other tools to validate profiles.
+func Schema() ([]byte, error) {
+	root := schemaOf(reflect.TypeOf(Profile{}))
+	root["$schema"] = "http://json-schema.org/draft-07/schema#"
+	root["title"] = "resetti profile"
+	return json.MarshalIndent(root, "", "  ")
+}
+
+// schemaOf returns the schema of the given type.
+func schemaOf(typ reflect.Type) schema {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if s, ok := schemaTypes[typ]; ok {
+		return s
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return schema{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return schema{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return schema{"type": "number"}
+	case reflect.String:
+		return schema{"type": "string"}
+	case reflect.Map:
+		return schema{"type": "object", "additionalProperties": schemaOf(typ.Elem())}
+	case reflect.Slice, reflect.Array:
+		return schema{"type": "array", "items": schemaOf(typ.Elem())}
+	case reflect.Struct:
+		props := make(map[string]schema)
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			props[name] = schemaOf(field.Type)
+		}
+		return schema{
+			"type":                 "object",
+			"properties":           props,
+			"additionalProperties": false,
+		}
+	default:
+		return schema{}
+	}
+}
@@ -0,0 +1,153 @@
+package ctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tesselslate/resetti/internal/cfg"
+	"github.com/tesselslate/resetti/internal/log"
+)
+
+// How long to wait after a reset before archiving the previous world, so that
+// the game has time to finish saving it.
+const archiveDelay = 5 * time.Second
+
+// runArchiver copies worlds which were played for long enough, along with the
+// game log and session statistics, into an archive directory so that runs can
+// be verified later.
+type runArchiver struct {
+	conf      *cfg.Archive
+	dir       string      // .minecraft directory of the instance
+	holds     *worldHolds // Worlds the world cleaner must not remove
+	lastReset time.Time   // When the played world was created
+}
+
+// archiveRun is a played world which is waiting to be archived.
+type archiveRun struct {
+	world  string
+	played time.Duration
+}
+
+// newRunArchiver creates a new runArchiver for the given instance directory.
+func newRunArchiver(conf *cfg.Archive, dir string, holds *worldHolds) *runArchiver {
+	return &runArchiver{conf, dir, holds, time.Now()}
+}
+
+// Begin finds the world which is being played, before the instance is reset
+// and creates a new world. If it was played for long enough, it is held back
+// from the world cleaner and returned so that Finish can archive it. It is
+// safe to call on a nil runArchiver.
+func (a *runArchiver) Begin() *archiveRun {
+	if a == nil {
+		return nil
+	}
+	played := time.Since(a.lastReset)
+	if played < time.Duration(a.conf.MinTime)*time.Second {
+		return nil
+	}
+	worlds, err := listWorlds(a.dir + "/saves")
+	if err != nil {
+		log.Error("Archive run failed: %s", err)
+		return nil
+	}
+	if len(worlds) == 0 {
+		return nil
+	}
+	a.holds.Add(worlds[0].name)
+	return &archiveRun{worlds[0].name, played}
+}
+
+// Finish is called once the instance has been reset (or failed to reset.) If
+// the reset went through, the world returned by Begin is archived in the
+// background. It is safe to call on a nil runArchiver.
+func (a *runArchiver) Finish(run *archiveRun, reset bool, session SessionStats) {
+	if a == nil {
+		return
+	}
+	if reset {
+		a.lastReset = time.Now()
+	}
+	if run == nil {
+		return
+	}
+	if !reset {
+		a.holds.Remove(run.world)
+		return
+	}
+	dest := filepath.Join(a.conf.Dir, time.Now().Format("2006-01-02_15-04-05")+" "+run.world)
+	go func() {
+		defer a.holds.Remove(run.world)
+		time.Sleep(archiveDelay)
+		if err := a.archive(run.world, dest, session); err != nil {
+			log.Error("Archive run failed: %s", err)
+			return
+		}
+		log.Info("Archived %q (played for %s) to %s", run.world, run.played.Round(time.Second), dest)
+	}()
+}
+
+// archive writes the given world, the game log, and the session statistics to
+// the destination directory.
+func (a *runArchiver) archive(world string, dest string, session SessionStats) error {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	src := filepath.Join(a.dir, "saves", world)
+	if err := copyDir(src, filepath.Join(dest, world)); err != nil {
+		return fmt.Errorf("copy world: %w", err)
+	}
+	if err := copyFile(filepath.Join(a.dir, "logs", "latest.log"), filepath.Join(dest, "latest.log")); err != nil {
+		return fmt.Errorf("copy log: %w", err)
+	}
+	buf, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dest, "stats.json"), buf, 0644)
+}
+
+// copyDir recursively copies a directory.
+func copyDir(src string, dest string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if entry.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		return copyFile(path, target)
+	})
+}
+
+// copyFile copies a single file.
+func copyFile(src string, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
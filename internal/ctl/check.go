@@ -0,0 +1,139 @@
+package ctl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tesselslate/resetti/internal/cfg"
+	"github.com/tesselslate/resetti/internal/mc"
+	"github.com/tesselslate/resetti/internal/x11"
+)
+
+// profileChecker checks a profile against the running environment and writes
+// the result of each check.
+type profileChecker struct {
+	w      io.Writer
+	failed bool
+}
+
+// CheckProfile checks that the given profile is valid and will work in the
+// current environment (e.g. that the instance can be found and that any
+// configured programs and servers are available) without starting a session.
+// A report is written to the given writer. It returns whether or not every
+// check passed.
+func CheckProfile(w io.Writer, profileName string) bool {
+	c := profileChecker{w: w}
+	profile, err := cfg.GetProfile(profileName)
+	c.report("Profile", err)
+	if err != nil {
+		return false
+	}
+
+	x, err := x11.NewClient()
+	c.report("X server connection", err)
+	if err == nil {
+		if profile.LayoutBinds {
+			keysyms, err := x.GetKeysymMapping()
+			if err == nil {
+				profile.Keybinds, err = profile.Keybinds.ResolveLayout(keysyms)
+			}
+			c.report("Keyboard layout", err)
+		}
+		_, err := mc.FindInstance(&x, &profile.Instance)
+		c.report("Minecraft instance", err)
+	}
+
+	for _, conflict := range profile.Keybinds.Conflicts() {
+		c.report("Keybinds", fmt.Errorf("%q and %q are the same input", conflict[0], conflict[1]))
+	}
+	if len(profile.Keybinds) == 0 {
+		c.report("Keybinds", errors.New("no keybinds are set"))
+	}
+
+	hooks := hookCommands(&profile.Hooks)
+	for hook, name := range hookNames {
+		for _, cmd := range hooks[hook] {
+			if cmd == "" {
+				continue
+			}
+			bin, _, _ := strings.Cut(cmd, " ")
+			_, err := exec.LookPath(bin)
+			c.report("Hook "+name, err)
+		}
+	}
+
+	sounds := []cfg.Sound{
+		profile.Sounds.Reset,
+		profile.Sounds.AltRes,
+		profile.Sounds.NormalRes,
+		profile.Sounds.FocusLost,
+		profile.Sounds.FocusGained,
+	}
+	hasSounds := false
+	for _, sound := range sounds {
+		if sound.File == "" {
+			continue
+		}
+		_, err := os.Stat(sound.File)
+		c.report("Sound "+sound.File, err)
+		hasSounds = true
+	}
+	if hasSounds {
+		_, err := exec.LookPath("paplay")
+		c.report("Sound player", err)
+	}
+	if profile.Notifications.Enabled {
+		_, err := exec.LookPath("notify-send")
+		c.report("Notifications", err)
+	}
+
+	if profile.LiveSplit.Address != "" {
+		conn, err := net.DialTimeout("tcp", profile.LiveSplit.Address, time.Second)
+		if err == nil {
+			_ = conn.Close()
+		}
+		c.report("LiveSplit server", err)
+	}
+	if profile.Remote.Address != "" {
+		listener, err := net.Listen("tcp", profile.Remote.Address)
+		if err == nil {
+			_ = listener.Close()
+		}
+		c.report("Remote control address", err)
+	}
+
+	dirs := [][2]string{
+		{"Counter file", filepath.Dir(profile.Counter.File)},
+		{"Daily counter file", filepath.Dir(profile.Counter.DailyFile)},
+		{"Archive directory", profile.Archive.Dir},
+	}
+	for _, dir := range dirs {
+		if dir[1] == "" || dir[1] == "." {
+			continue
+		}
+		stat, err := os.Stat(dir[1])
+		if err == nil && !stat.IsDir() {
+			err = fmt.Errorf("%s is not a directory", dir[1])
+		}
+		c.report(dir[0], err)
+	}
+
+	return !c.failed
+}
+
+// report writes the result of a single check.
+func (c *profileChecker) report(name string, err error) {
+	if err != nil {
+		c.failed = true
+		fmt.Fprintf(c.w, "[FAIL] %s: %s\n", name, err)
+	} else {
+		fmt.Fprintf(c.w, "[PASS] %s\n", name)
+	}
+}
@@ -0,0 +1,303 @@
+package ctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tesselslate/resetti/internal/cfg"
+	"github.com/tesselslate/resetti/internal/log"
+	"github.com/tesselslate/resetti/internal/res"
+)
+
+// ledgerFile is the path of the daily reset ledger within the data directory.
+const ledgerFile = "/ledger.json"
+
+// A counterBackend stores or displays the reset count.
+type counterBackend interface {
+	// Update is called with the new reset counts after every reset.
+	Update(counts resetCounts) error
+}
+
+// resetCounts contains the number of resets over several periods of time.
+type resetCounts struct {
+	Total   int `json:"resets"`  // All resets
+	Daily   int `json:"daily"`   // Resets since the last daily rollover
+	Session int `json:"session"` // Resets since resetti started
+}
+
+// resetCounter keeps track of the number of resets and sends them to each of
+// the configured backends.
+type resetCounter struct {
+	mu       sync.Mutex
+	counts   resetCounts
+	backends []counterBackend
+
+	rollover time.Duration  // Time of day at which the daily count resets
+	day      string         // The current day (YYYY-MM-DD)
+	ledger   map[string]int // Resets per day
+}
+
+// fileCounter writes one of the reset counts to a file (e.g. for an OBS text
+// source.)
+type fileCounter struct {
+	path  string
+	daily bool // Whether to write the daily count instead of the total
+}
+
+// attemptsCounter keeps an instance's SpeedRunIGT attempts file in sync with
+// the total reset count.
+type attemptsCounter struct {
+	path string
+}
+
+// httpCounter sends the reset counts to a URL with a POST request.
+type httpCounter struct {
+	url    string
+	client http.Client
+}
+
+// newResetCounter creates a new resetCounter with the backends from the given
+// configuration. If a counter file is configured, the total count is read from
+// it, and the daily count is read from the ledger.
+func newResetCounter(conf *cfg.Counter) (*resetCounter, error) {
+	c := &resetCounter{}
+	if conf.Rollover != "" {
+		rollover, err := parseTimeOfDay(conf.Rollover)
+		if err != nil {
+			return nil, fmt.Errorf("parse rollover time: %w", err)
+		}
+		c.rollover = rollover
+	}
+	ledger, err := readLedger()
+	if err != nil {
+		return nil, err
+	}
+	c.ledger = ledger
+	c.day = c.currentDay()
+	c.counts.Daily = c.ledger[c.day]
+
+	if conf.File != "" {
+		count, err := readCountFile(conf.File)
+		if err != nil {
+			return nil, fmt.Errorf("read counter file: %w", err)
+		}
+		c.counts.Total = count
+		c.backends = append(c.backends, &fileCounter{conf.File, false})
+	}
+	if conf.DailyFile != "" {
+		c.backends = append(c.backends, &fileCounter{conf.DailyFile, true})
+	}
+	if conf.Url != "" {
+		c.backends = append(c.backends, &httpCounter{
+			conf.Url,
+			http.Client{Timeout: 5 * time.Second},
+		})
+	}
+	return c, nil
+}
+
+// SyncAttempts starts keeping the SpeedRunIGT attempts file at the given path
+// (relative to the instance directory) in sync with the total count. The
+// counts are merged by taking the larger of the two, since both count the same
+// resets; if the attempts file is ahead (e.g. after resetting without
+// resetti), the other backends are updated to match. If the attempts file
+// cannot be read, it is left alone and not synced.
+func (c *resetCounter) SyncAttempts(dir string, path string) error {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	attempts, err := readCountFile(path)
+	if err != nil {
+		return fmt.Errorf("read attempts file: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	backend := &attemptsCounter{path}
+	if attempts > c.counts.Total {
+		log.Info("Reset count is behind SpeedRunIGT attempts (%d < %d), updating.", c.counts.Total, attempts)
+		c.counts.Total = attempts
+		for _, other := range c.backends {
+			if err := other.Update(c.counts); err != nil {
+				log.Error("Update reset counter (%T) failed: %s", other, err)
+			}
+		}
+	}
+	c.backends = append(c.backends, backend)
+	if attempts != c.counts.Total {
+		return backend.Update(c.counts)
+	}
+	return nil
+}
+
+// parseTimeOfDay parses a time of day in the format HH:MM.
+func parseTimeOfDay(str string) (time.Duration, error) {
+	t, err := time.Parse("15:04", str)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// PrintLedger prints the number of resets on each day recorded in the ledger
+// to the given writer.
+func PrintLedger(w io.Writer) error {
+	ledger, err := readLedger()
+	if err != nil {
+		return err
+	}
+	if len(ledger) == 0 {
+		return nil
+	}
+	days := make([]string, 0, len(ledger))
+	for day := range ledger {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	fmt.Fprintln(w, "Resets by day:")
+	for _, day := range days {
+		fmt.Fprintf(w, "  %s: %d\n", day, ledger[day])
+	}
+	return nil
+}
+
+// readCountFile reads the reset count from the given file. If it does not
+// exist or is empty, the count is 0.
+func readCountFile(path string) (int, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	str := strings.TrimSpace(string(buf))
+	if str == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(str)
+}
+
+// readLedger reads the daily reset ledger. If it does not exist, an empty
+// ledger is returned.
+func readLedger() (map[string]int, error) {
+	ledger := make(map[string]int)
+	buf, err := os.ReadFile(res.GetDataDirectory() + ledgerFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ledger, nil
+		}
+		return nil, fmt.Errorf("read ledger: %w", err)
+	}
+	if err := json.Unmarshal(buf, &ledger); err != nil {
+		return nil, fmt.Errorf("parse ledger: %w", err)
+	}
+	return ledger, nil
+}
+
+// Counts returns the current reset counts.
+func (c *resetCounter) Counts() resetCounts {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkRollover()
+	return c.counts
+}
+
+// Increment increments the reset counts and updates every backend and the
+// ledger. Any errors are logged.
+func (c *resetCounter) Increment() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkRollover()
+	c.counts.Total += 1
+	c.counts.Daily += 1
+	c.counts.Session += 1
+	c.ledger[c.day] = c.counts.Daily
+	for _, backend := range c.backends {
+		if err := backend.Update(c.counts); err != nil {
+			log.Error("Update reset counter (%T) failed: %s", backend, err)
+		}
+	}
+	if err := c.writeLedger(); err != nil {
+		log.Error("Update reset ledger failed: %s", err)
+	}
+}
+
+// checkRollover resets the daily count if the rollover time has passed since
+// the last reset. The caller must hold the mutex.
+func (c *resetCounter) checkRollover() {
+	day := c.currentDay()
+	if day == c.day {
+		return
+	}
+	log.Info("Daily reset count rolled over (%d resets on %s.)", c.counts.Daily, c.day)
+	c.day = day
+	c.counts.Daily = c.ledger[day]
+}
+
+// currentDay returns the day which resets are currently counted towards.
+func (c *resetCounter) currentDay() string {
+	return countedDay(time.Now(), c.rollover)
+}
+
+// countedDay returns the day which resets at the given time are counted
+// towards. If the time is before the rollover time, it is the previous day.
+func countedDay(t time.Time, rollover time.Duration) string {
+	return t.Add(-rollover).Format(time.DateOnly)
+}
+
+// writeLedger writes the daily reset ledger to disk. The caller must hold the
+// mutex.
+func (c *resetCounter) writeLedger() error {
+	buf, err := json.MarshalIndent(c.ledger, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(res.GetDataDirectory()+ledgerFile, buf, 0644)
+}
+
+// Update implements counterBackend.
+func (f *fileCounter) Update(counts resetCounts) error {
+	count := counts.Total
+	if f.daily {
+		count = counts.Daily
+	}
+	return os.WriteFile(f.path, []byte(strconv.Itoa(count)), 0644)
+}
+
+// Update implements counterBackend.
+func (a *attemptsCounter) Update(counts resetCounts) error {
+	return os.WriteFile(a.path, []byte(strconv.Itoa(counts.Total)), 0644)
+}
+
+// Update implements counterBackend. The request is sent in the background so
+// that a slow server does not block the caller.
+func (h *httpCounter) Update(counts resetCounts) error {
+	body, err := json.Marshal(counts)
+	if err != nil {
+		return err
+	}
+	go func() {
+		resp, err := h.client.Post(h.url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Error("Update HTTP reset counter failed: %s", err)
+			return
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			log.Error("Update HTTP reset counter failed: %s", resp.Status)
+		}
+	}()
+	return nil
+}
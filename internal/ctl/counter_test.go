@@ -0,0 +1,128 @@
+package ctl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tesselslate/resetti/internal/log"
+)
+
+func TestParseTimeOfDay(t *testing.T) {
+	tests := []struct {
+		str  string
+		want time.Duration
+		ok   bool
+	}{
+		{"00:00", 0, true},
+		{"04:30", 4*time.Hour + 30*time.Minute, true},
+		{"23:59", 23*time.Hour + 59*time.Minute, true},
+		{"24:00", 0, false},
+		{"4pm", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeOfDay(tt.str)
+		if tt.ok && err != nil {
+			t.Errorf("parseTimeOfDay(%q) got error %q", tt.str, err)
+		} else if !tt.ok && err == nil {
+			t.Errorf("parseTimeOfDay(%q) got no error", tt.str)
+		} else if got != tt.want {
+			t.Errorf("parseTimeOfDay(%q) = %s, want %s", tt.str, got, tt.want)
+		}
+	}
+}
+
+func TestCountedDay(t *testing.T) {
+	at := func(hour, min int) time.Time {
+		return time.Date(2023, time.June, 2, hour, min, 0, 0, time.Local)
+	}
+	tests := []struct {
+		time     time.Time
+		rollover time.Duration
+		want     string
+	}{
+		{at(0, 0), 0, "2023-06-02"},
+		{at(23, 59), 0, "2023-06-02"},
+		{at(3, 59), 4 * time.Hour, "2023-06-01"},
+		{at(4, 0), 4 * time.Hour, "2023-06-02"},
+		{at(23, 59), 4 * time.Hour, "2023-06-02"},
+		{time.Date(2023, time.January, 1, 1, 0, 0, 0, time.Local), 2 * time.Hour, "2022-12-31"},
+	}
+	for _, tt := range tests {
+		if got := countedDay(tt.time, tt.rollover); got != tt.want {
+			t.Errorf("countedDay(%s, %s) = %s, want %s", tt.time.Format(time.TimeOnly), tt.rollover, got, tt.want)
+		}
+	}
+}
+
+func TestCheckRollover(t *testing.T) {
+	log.Detach()
+	today := countedDay(time.Now(), 0)
+	tests := []struct {
+		name   string
+		day    string
+		ledger map[string]int
+		daily  int
+		want   int
+	}{
+		{"same day", today, map[string]int{today: 3}, 5, 5},
+		{"new day", "2000-01-01", map[string]int{"2000-01-01": 5}, 5, 0},
+		{"new day in ledger", "2000-01-01", map[string]int{"2000-01-01": 5, today: 3}, 5, 3},
+	}
+	for _, tt := range tests {
+		c := &resetCounter{day: tt.day, ledger: tt.ledger}
+		c.counts = resetCounts{Total: 100, Daily: tt.daily, Session: 10}
+		c.checkRollover()
+		if c.day != today {
+			t.Errorf("%s: day is %s, want %s", tt.name, c.day, today)
+		}
+		want := resetCounts{Total: 100, Daily: tt.want, Session: 10}
+		if c.counts != want {
+			t.Errorf("%s: counts are %+v, want %+v", tt.name, c.counts, want)
+		}
+	}
+}
+
+func TestSyncAttempts(t *testing.T) {
+	log.Detach()
+	tests := []struct {
+		name     string
+		contents string
+		total    int
+		ok       bool
+		want     int
+		file     string
+	}{
+		{"behind", "50", 40, true, 50, "50"},
+		{"ahead", "30", 40, true, 40, "40"},
+		{"empty", "", 40, true, 40, "40"},
+		{"not a number", "many", 40, false, 40, "many"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "attempts.txt")
+		if err := os.WriteFile(path, []byte(tt.contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		c := &resetCounter{counts: resetCounts{Total: tt.total}}
+		err := c.SyncAttempts(dir, "attempts.txt")
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got error %v, want ok=%v", tt.name, err, tt.ok)
+		}
+		if c.counts.Total != tt.want {
+			t.Errorf("%s: total is %d, want %d", tt.name, c.counts.Total, tt.want)
+		}
+		if synced := len(c.backends) == 1; synced != tt.ok {
+			t.Errorf("%s: synced is %v, want %v", tt.name, synced, tt.ok)
+		}
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != tt.file {
+			t.Errorf("%s: attempts file is %q, want %q", tt.name, buf, tt.file)
+		}
+	}
+}
@@ -4,10 +4,13 @@ package ctl
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"os/signal"
+	"runtime/debug"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -28,26 +31,81 @@ const (
 	HookNormalRes
 	HookFocusLost
 	HookFocusGained
+	HookExit
+	HookCrash
 )
 
+// How long the exit hook may run before it is killed, so that a hung hook
+// cannot stop resetti from exiting.
+const exitHookTimeout = 5 * time.Second
+
+// Hook names, as passed to hook commands
+var hookNames = [...]string{
+	"reset",
+	"alt_res",
+	"normal_res",
+	"focus_lost",
+	"focus_gained",
+	"exit",
+	"crash",
+}
+
+// hookCommands returns the commands configured for each hook type. The
+// resolution hooks have one entry per alternate resolution; the others have a
+// single entry, which may be empty.
+func hookCommands(hooks *cfg.Hooks) map[int][]string {
+	return map[int][]string{
+		HookReset:       {hooks.Reset},
+		HookAltRes:      hooks.AltRes,
+		HookNormalRes:   hooks.NormalRes,
+		HookFocusLost:   {hooks.FocusLost},
+		HookFocusGained: {hooks.FocusGained},
+		HookExit:        {hooks.Exit},
+		HookCrash:       {hooks.Crash},
+	}
+}
+
 // Controller manages all of the components necessary for resetti to run and
 // handles communication between them.
 type Controller struct {
-	conf *cfg.Profile
-	dbg  *debugLogger
-	x    *x11.Client
-
-	manager  *mc.Manager
-	frontend Frontend
+	conf    *cfg.Profile
+	dbg     *debugLogger
+	x       *x11.Client
+	bus     eventBus
+	stats   *statsTracker
+	notif   *notifier
+	counter *resetCounter
+
+	remote     *remoteServer
+	remoteCmds <-chan remoteCommand
+	livesplit  *livesplitClient
+	archiver   *runArchiver
+	holds      *worldHolds
+	diag       *xDiagnostics
+
+	instance    mc.InstanceInfo
+	manager     *mc.Manager
+	crashReport string // Path to the last crash report (if any.)
+	frontend    Frontend
 
 	binds    map[cfg.Bind]cfg.ActionList
 	inputMgr inputManager
 	inputs   <-chan Input
+	recorder *inputRecorder
 	hooks    map[int][]string
+	sounds   *soundPlayer
 
+	mgrErrors <-chan error
 	x11Events <-chan x11.Event
 	x11Errors <-chan error
 	signals   <-chan os.Signal
+	panics    chan any // Panics recovered by goSafely.
+}
+
+// Options contains command line options which change how the Controller runs.
+type Options struct {
+	RecordPath string // File to record the user's inputs to (if any.)
+	ReplayPath string // File to replay inputs from (if any.)
 }
 
 // A Frontend handles user-facing I/O (input handling, instance actions, OBS
@@ -90,8 +148,9 @@ type inputManager struct {
 	lastFailWindow xproto.Window // The last window QueryPointer failed on.
 }
 
-// Run creates a new controller with the given configuration profile and runs it.
-func Run(conf *cfg.Profile) error {
+// Run creates a new controller with the given configuration profile and options
+// and runs it.
+func Run(conf *cfg.Profile, opts Options) error {
 	defer log.Info("Done")
 	wg := sync.WaitGroup{}
 	defer wg.Wait()
@@ -100,15 +159,20 @@ func Run(conf *cfg.Profile) error {
 
 	c := Controller{}
 	c.dbg = &debugLogger{&c}
+	c.panics = make(chan any, 1)
 	c.conf = conf
+	c.stats = newStatsTracker()
 	c.binds = make(map[cfg.Bind]cfg.ActionList)
-	c.hooks = map[int][]string{
-		HookReset:       {c.conf.Hooks.Reset},
-		HookAltRes:      c.conf.Hooks.AltRes,
-		HookNormalRes:   c.conf.Hooks.NormalRes,
-		HookFocusLost:   {c.conf.Hooks.FocusLost},
-		HookFocusGained: {c.conf.Hooks.FocusGained},
+	c.hooks = hookCommands(&c.conf.Hooks)
+	c.sounds = newSoundPlayer(c.conf)
+	c.notif = newNotifier(c.conf)
+	counter, err := newResetCounter(&c.conf.Counter)
+	if err != nil {
+		return fmt.Errorf("(init) create reset counter: %w", err)
 	}
+	c.counter = counter
+	c.bus.Subscribe(c.stats)
+	c.bus.Subscribe(c.counter)
 
 	x, err := x11.NewClient()
 	if err != nil {
@@ -116,7 +180,18 @@ func Run(conf *cfg.Profile) error {
 	}
 	c.x = &x
 
-	instance, err := mc.FindInstance(&x)
+	if conf.LayoutBinds {
+		keysyms, err := x.GetKeysymMapping()
+		if err != nil {
+			return fmt.Errorf("(init) get keyboard mapping: %w", err)
+		}
+		conf.Keybinds, err = conf.Keybinds.ResolveLayout(keysyms)
+		if err != nil {
+			return fmt.Errorf("(init) resolve keybinds: %w", err)
+		}
+	}
+
+	instance, err := mc.FindInstance(&x, &conf.Instance)
 	if err != nil {
 		return fmt.Errorf("(init) find instance: %w", err)
 	}
@@ -125,12 +200,24 @@ func Run(conf *cfg.Profile) error {
 	} else {
 		log.Info("Instance detected does not have modern WorldPreview")
 	}
+	c.instance = instance
+	if conf.Counter.AttemptsFile != "" {
+		if err := c.counter.SyncAttempts(instance.Dir, conf.Counter.AttemptsFile); err != nil {
+			log.Warn("Failed to sync attempts file: %s", err)
+		}
+	}
 
 	c.manager, err = mc.NewManager(instance, conf, &x)
 	if err != nil {
 		return fmt.Errorf("(init) create manager: %w", err)
 	}
 
+	mgrErrors := make(chan error, 1)
+	c.mgrErrors = mgrErrors
+	c.goSafely(&wg, func() {
+		c.manager.Run(ctx, mgrErrors)
+	})
+
 	c.frontend = &Single{}
 
 	// Start various components
@@ -151,18 +238,89 @@ func Run(conf *cfg.Profile) error {
 	inputs := make(chan Input, 256)
 	c.inputMgr = inputManager{c.conf, c.x, nil, 0}
 	c.inputs = inputs
-	go c.inputMgr.Run(inputs)
+	c.goSafely(nil, func() {
+		c.inputMgr.Run(inputs)
+	})
+
+	if opts.RecordPath != "" {
+		c.recorder, err = newInputRecorder(opts.RecordPath)
+		if err != nil {
+			return fmt.Errorf("(init) create input recording: %w", err)
+		}
+		defer c.recorder.Close()
+		log.Info("Recording inputs to %s", opts.RecordPath)
+	}
+	if opts.ReplayPath != "" {
+		c.goSafely(&wg, func() {
+			log.Info("Replaying inputs from %s", opts.ReplayPath)
+			if err := replayInputs(ctx, opts.ReplayPath, c.conf.Keybinds, inputs); err != nil {
+				log.Error("Replay inputs failed: %s", err)
+			} else {
+				log.Info("Finished replaying inputs.")
+			}
+		})
+	}
+
+	if c.conf.Remote.Address != "" {
+		remoteCmds := make(chan remoteCommand, 16)
+		c.remote = newRemoteServer(&c.conf.Remote, remoteCmds, c.snapshot)
+		c.remoteCmds = remoteCmds
+		c.bus.Subscribe(c.remote)
+		c.goSafely(&wg, func() {
+			c.remote.Run(ctx)
+		})
+	}
+
+	if c.conf.LiveSplit.Address != "" {
+		c.livesplit = newLivesplitClient(&c.conf.LiveSplit)
+		c.bus.Subscribe(c.livesplit)
+		c.goSafely(&wg, func() {
+			c.livesplit.Run(ctx)
+		})
+	}
+
+	c.holds = newWorldHolds()
+	if c.conf.Archive.Dir != "" {
+		c.archiver = newRunArchiver(&c.conf.Archive, c.instance.Dir, c.holds)
+	}
+
+	if c.conf.Worlds.Keep > 0 || c.conf.Worlds.MaxAge > 0 {
+		cleaner := newWorldCleaner(&c.conf.Worlds, c.instance.Dir, c.holds)
+		c.goSafely(&wg, func() {
+			cleaner.Run(ctx)
+		})
+	}
+
+	if c.conf.Diagnostics.Interval > 0 || c.conf.Diagnostics.Recalibrate {
+		c.diag = newXDiagnostics(&c.conf.Diagnostics, c.x)
+		c.goSafely(&wg, func() {
+			c.diag.Run(ctx)
+		})
+	}
 
 	signals := make(chan os.Signal, 8)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR1)
 	c.signals = signals
 
 	log.Info("Ready.")
-	go c.dbg.Run()
-	err = c.run()
+	c.goSafely(nil, c.dbg.Run)
+	err = c.runSafely()
 	if err != nil {
 		fmt.Println("Failed to run:", err)
 	}
+	if c.conf.Unredirect {
+		c.manager.BypassCompositor(false)
+	}
+	hookCtx, hookCancel := context.WithTimeout(context.Background(), exitHookTimeout)
+	defer hookCancel()
+	if cmd := c.hookCommand(hookCtx, HookExit, 0); cmd != nil {
+		if err := cmd.Run(); err != nil {
+			log.Error("RunHook (%s) failed: %s", hookNames[HookExit], err)
+		}
+	}
+	if err := c.stats.Save(); err != nil {
+		log.Error("Failed to save stats: %s", err)
+	}
 	return nil
 }
 
@@ -174,7 +332,9 @@ func (c *Controller) FocusInstance() {
 // ToggleResolution switches the given instance between the normal (play)
 // resolution and the given alternate resolution.
 func (c *Controller) ToggleResolution(resId int) {
-	if c.manager.ToggleResolution(resId) {
+	altRes := c.manager.ToggleResolution(resId)
+	c.bus.Publish(resolutionEvent{c.instance.Dir, altRes, resId})
+	if altRes {
 		c.RunHook(HookAltRes, resId)
 	} else {
 		c.RunHook(HookNormalRes, resId)
@@ -184,31 +344,168 @@ func (c *Controller) ToggleResolution(resId int) {
 // ResetInstance attempts to reset the given instance and returns whether or
 // not the reset was successful.
 func (c *Controller) ResetInstance() bool {
-	return c.manager.Reset()
+	// The played world must be found before the new world is created, but
+	// it is only archived once the reset has been issued.
+	run := c.archiver.Begin()
+	ok := c.manager.Reset()
+	c.archiver.Finish(run, ok, c.stats.Snapshot())
+	if !ok {
+		return false
+	}
+	c.bus.Publish(resetEvent{c.instance.Dir})
+	return true
+}
+
+// recoverInstance performs the configured watchdog action on the stuck
+// instance. Resets go through ResetInstance like any other reset, so that
+// they are counted and trigger the usual hooks.
+func (c *Controller) recoverInstance() {
+	switch c.conf.Watchdog.Action {
+	case "reset":
+		log.Info("Watchdog: Resetting stuck instance.")
+		if c.ResetInstance() {
+			c.RunHook(HookReset, 0)
+		}
+	case "sigcont":
+		log.Info("Watchdog: Continuing stuck instance.")
+		c.manager.Continue()
+	}
 }
 
-// RunHook runs the hook of the given type if it exists.
+// RunHook runs the hook of the given type if it exists, and plays the sound
+// for the hook type if there is one.
 func (c *Controller) RunHook(hook int, hookId int) {
+	c.sounds.Play(hook)
+	cmd := c.hookCommand(context.Background(), hook, hookId)
+	if cmd == nil {
+		return
+	}
+	go func() {
+		err := cmd.Run()
+		if err != nil {
+			log.Error("RunHook (%s) failed: %s", hookNames[hook], err)
+		}
+	}()
+}
+
+// hookCommand creates the command for the given hook, with any placeholders in
+// its arguments replaced and the hook's variables set in its environment. The
+// command is killed if the context is cancelled. If the hook does not exist,
+// nil is returned.
+func (c *Controller) hookCommand(ctx context.Context, hook int, hookId int) *exec.Cmd {
 	if hookId >= len(c.hooks[hook]) {
 		log.Error("RunHook: hook id %d out of bounds", hookId)
-		return
+		return nil
 	}
 	cmdStr := c.hooks[hook][hookId]
 	if cmdStr == "" {
-		return
+		return nil
+	}
+	// The resolution is only meaningful for resolution hooks.
+	res := ""
+	if hook == HookAltRes || hook == HookNormalRes {
+		res = strconv.Itoa(hookId)
+	}
+	counts := c.counter.Counts()
+	vars := []hookVar{
+		{"count", strconv.Itoa(counts.Total)},
+		{"crash", c.crashReport},
+		{"daily", strconv.Itoa(counts.Daily)},
+		{"hook", hookNames[hook]},
+		{"instance", c.instance.Dir},
+		{"pid", strconv.FormatUint(uint64(c.instance.Pid), 10)},
+		{"res", res},
+		{"resets", strconv.Itoa(c.stats.Resets(c.instance.Dir))},
+		{"version", strconv.Itoa(c.instance.Version)},
+	}
+	bin, args := expandHook(cmdStr, vars)
+	env := os.Environ()
+	for _, v := range vars {
+		env = append(env, "RESETTI_"+strings.ToUpper(v.name)+"="+v.value)
+	}
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Env = env
+	return cmd
+}
+
+// hookVar is a variable which is available to hook commands, both as a
+// placeholder in their arguments and as an environment variable.
+type hookVar struct {
+	name  string
+	value string
+}
+
+// expandHook splits a hook command into its program and arguments, and
+// replaces the placeholders for the given variables in the arguments. All
+// placeholders are replaced in a single pass, so a value which contains a
+// placeholder (e.g. a directory named "{count}") is left as-is.
+func expandHook(cmdStr string, vars []hookVar) (string, []string) {
+	bin, rawArgs, ok := strings.Cut(cmdStr, " ")
+	if !ok {
+		return bin, nil
+	}
+	pairs := make([]string, 0, len(vars)*2)
+	for _, v := range vars {
+		pairs = append(pairs, "{"+v.name+"}", v.value)
+	}
+	replacer := strings.NewReplacer(pairs...)
+	args := strings.Split(rawArgs, " ")
+	for i := range args {
+		args[i] = replacer.Replace(args[i])
+	}
+	return bin, args
+}
+
+// goSafely runs the given function in a new goroutine, which is added to the
+// wait group (if one is given.) If the function panics, the panic is passed to
+// the main loop, which then shuts down as if it had panicked itself.
+func (c *Controller) goSafely(wg *sync.WaitGroup, fn func()) {
+	if wg != nil {
+		wg.Add(1)
 	}
 	go func() {
-		bin, rawArgs, ok := strings.Cut(cmdStr, " ")
-		var args []string
-		if ok {
-			args = strings.Split(rawArgs, " ")
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("Panic: %v\n%s", r, debug.Stack())
+				select {
+				case c.panics <- r:
+				default:
+					// The main loop is already shutting down because of
+					// another panic.
+				}
+			}
+		}()
+		if wg != nil {
+			defer wg.Done()
 		}
-		cmd := exec.Command(bin, args...)
-		err := cmd.Run()
-		if err != nil {
-			log.Error("RunHook (%d) failed: %s", hook, err)
+		fn()
+	}()
+}
+
+// runSafely runs the main loop for the controller. If it panics, the panic is
+// logged and the instance and X server are put back into a usable state (the
+// instance is continued and the pointer is ungrabbed) so that resetti can shut
+// down normally.
+func (c *Controller) runSafely() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("Panic: %v\n%s", r, debug.Stack())
+			err = c.handlePanic(r)
 		}
 	}()
+	return c.run()
+}
+
+// handlePanic leaves the instance and X server in a usable state after a panic
+// so that resetti can shut down. It waits for the user to be notified, since
+// resetti exits right after.
+func (c *Controller) handlePanic(r any) error {
+	c.manager.Continue()
+	if err := c.x.UngrabPointer(); err != nil {
+		log.Error("Ungrab pointer failed: %s", err)
+	}
+	c.notif.NotifyWait(notifyPanic, "resetti crashed", fmt.Sprint(r))
+	return fmt.Errorf("panic: %v", r)
 }
 
 // run runs the main loop for the controller.
@@ -223,16 +520,96 @@ func (c *Controller) run() error {
 			case syscall.SIGUSR1:
 				c.dbg.printAll()
 			}
+		case r := <-c.panics:
+			return c.handlePanic(r)
+		case err := <-c.mgrErrors:
+			var crash *mc.CrashError
+			if errors.As(err, &crash) {
+				c.crashReport = crash.Report
+				c.notif.Notify(notifyInstanceCrashed, "Instance crashed", crash.Report)
+				c.RunHook(HookCrash, 0)
+				continue
+			}
+			if errors.Is(err, mc.ErrInstanceStuck) {
+				c.notif.Notify(notifyInstanceStuck, "Instance stuck", err.Error())
+				c.recoverInstance()
+				continue
+			}
+			log.Error("Manager error: %s", err)
+			c.notif.Notify(notifyInstanceDied, "Instance died", err.Error())
 		case err, ok := <-c.x11Errors:
 			if !ok {
+				c.notif.Notify(notifyXError, "Lost connection to X server", "resetti is shutting down.")
 				return fmt.Errorf("fatal X error: %w", err)
 			}
 			log.Error("X error: %s", err)
+			c.notif.Notify(notifyXError, "X error", err.Error())
 		case evt := <-c.x11Events:
+			if evt, ok := evt.(x11.FocusEvent); ok {
+				focused := xproto.Window(evt) == c.instance.Wid
+				if c.conf.Unredirect {
+					c.manager.BypassCompositor(focused)
+				}
+				c.bus.Publish(focusEvent{c.instance.Dir, focused})
+			}
 			c.frontend.ProcessEvent(evt)
 		case input := <-c.inputs:
+			c.recorder.Record(input)
 			c.frontend.Input(input)
+		case cmd := <-c.remoteCmds:
+			c.handleRemoteCommand(cmd)
+		}
+	}
+}
+
+// snapshot returns the current state of resetti for remote control clients.
+// It is safe to call from any goroutine.
+func (c *Controller) snapshot() remoteSnapshot {
+	active := -1
+	if c.x.GetActiveWindow() == c.instance.Wid {
+		active = 0
+	}
+	resolutions := make([]string, len(c.conf.AltRes))
+	for i, res := range c.conf.AltRes {
+		resolutions[i] = res.Name
+		if res.Name == "" {
+			resolutions[i] = fmt.Sprintf("%dx%d", res.W, res.H)
+		}
+	}
+	return remoteSnapshot{
+		Schema: 1,
+		Instances: []instanceSnapshot{{
+			Dir:     c.instance.Dir,
+			Pid:     c.instance.Pid,
+			Window:  uint32(c.instance.Wid),
+			Version: c.instance.Version,
+			AltRes:  c.manager.AltRes(),
+			Resets:  c.stats.Resets(c.instance.Dir),
+		}},
+		Active:      active,
+		Resolutions: resolutions,
+		Counts:      c.counter.Counts(),
+		Session:     c.stats.Snapshot(),
+	}
+}
+
+// handleRemoteCommand performs an action requested by a remote control client.
+func (c *Controller) handleRemoteCommand(cmd remoteCommand) {
+	switch cmd.Action {
+	case remoteReset:
+		if c.ResetInstance() {
+			c.RunHook(HookReset, 0)
+		}
+	case remoteFocus:
+		c.FocusInstance()
+	case remoteToggleRes:
+		if cmd.Res < 0 || cmd.Res > len(c.conf.AltRes)-1 {
+			log.Warn("Remote: alternate resolution %d does not exist", cmd.Res)
+			return
 		}
+		c.ToggleResolution(cmd.Res)
+	default:
+		log.Warn("Remote: unknown action %q", cmd.Action)
 	}
 }
 
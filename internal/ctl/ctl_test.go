@@ -0,0 +1,33 @@
+package ctl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandHook(t *testing.T) {
+	vars := []hookVar{
+		{"count", "1234"},
+		{"dir", "/home/user/{count}"},
+		{"res", ""},
+	}
+	tests := []struct {
+		cmd  string
+		bin  string
+		args []string
+	}{
+		{"notify-send", "notify-send", nil},
+		{"echo hello", "echo", []string{"hello"}},
+		{"echo {count}", "echo", []string{"1234"}},
+		{"echo resets={count}/{count}", "echo", []string{"resets=1234/1234"}},
+		{"ls {dir}", "ls", []string{"/home/user/{count}"}},
+		{"echo {res}", "echo", []string{""}},
+		{"echo {unknown} {Count}", "echo", []string{"{unknown}", "{Count}"}},
+	}
+	for _, tt := range tests {
+		bin, args := expandHook(tt.cmd, vars)
+		if bin != tt.bin || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("expandHook(%q) = %q %q, want %q %q", tt.cmd, bin, args, tt.bin, tt.args)
+		}
+	}
+}
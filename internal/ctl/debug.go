@@ -48,6 +48,10 @@ func (d *debugLogger) Run() {
 			d.printGc()
 		case "i", "input":
 			d.printInput()
+		case "s", "stats":
+			d.printStats()
+		case "x", "x11":
+			d.printX()
 		}
 	}
 }
@@ -56,6 +60,8 @@ func (d *debugLogger) printAll() {
 	d.printFrontend()
 	d.printGc()
 	d.printInput()
+	d.printStats()
+	d.printX()
 }
 
 func (d *debugLogger) printFrontend() {
@@ -87,3 +93,17 @@ func (d *debugLogger) printInput() {
 	fmt.Fprintf(s, "Last fail window: %d", d.host.inputMgr.lastFailWindow)
 	log.Debug(s.String())
 }
+
+func (d *debugLogger) printStats() {
+	s := &strings.Builder{}
+	s.WriteString("\nStats: \n")
+	printSession(s, d.host.stats.Snapshot())
+	log.Debug(strings.TrimSuffix(s.String(), "\n"))
+}
+
+func (d *debugLogger) printX() {
+	s := &strings.Builder{}
+	s.WriteString("\nX server: \n")
+	d.host.diag.Print(s, d.host.x)
+	log.Debug(strings.TrimSuffix(s.String(), "\n"))
+}
@@ -0,0 +1,154 @@
+package ctl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/tesselslate/resetti/internal/cfg"
+	"github.com/tesselslate/resetti/internal/log"
+	"github.com/tesselslate/resetti/internal/x11"
+)
+
+// How often to check whether the system has resumed from suspend.
+const resumeCheckInterval = time.Second
+
+// The amount by which the system clock must jump ahead of the monotonic clock
+// between two resume checks for the system to be considered resumed.
+const resumeThreshold = 2 * time.Second
+
+// xDiagnostics periodically measures the X server's round trip latency and how
+// far its clock has drifted from the time offset used for key events, and
+// warns if either is too high. It can also recalibrate the time offset when it
+// drifts or the system resumes from suspend, since the offset is otherwise
+// only computed at startup.
+type xDiagnostics struct {
+	conf *cfg.Diagnostics
+	x    *x11.Client
+
+	// The mutex guards the results of the last measurement.
+	mu       sync.Mutex
+	measured time.Time
+	latency  time.Duration
+	drift    int64
+}
+
+// newXDiagnostics creates a new xDiagnostics for the given X client.
+func newXDiagnostics(conf *cfg.Diagnostics, x *x11.Client) *xDiagnostics {
+	return &xDiagnostics{conf: conf, x: x}
+}
+
+// Run measures the X server at the configured interval (and after resuming
+// from suspend, if recalibration is enabled) until the context is cancelled.
+func (d *xDiagnostics) Run(ctx context.Context) {
+	var measureTick, resumeTick <-chan time.Time
+	if d.conf.Interval > 0 {
+		ticker := time.NewTicker(time.Duration(d.conf.Interval) * time.Second)
+		defer ticker.Stop()
+		measureTick = ticker.C
+	}
+	if d.conf.Recalibrate {
+		ticker := time.NewTicker(resumeCheckInterval)
+		defer ticker.Stop()
+		resumeTick = ticker.C
+	}
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-measureTick:
+			d.measure()
+		case now := <-resumeTick:
+			// The monotonic clock stops while the system is suspended, but
+			// the wall clock does not.
+			jump := now.Round(0).Sub(last.Round(0)) - now.Sub(last)
+			last = now
+			if jump > resumeThreshold {
+				log.Info("Diagnostics: system resumed from suspend, recalibrating")
+				d.recalibrate()
+			}
+		}
+	}
+}
+
+// Print writes the results of the last measurement, along with statistics
+// about the key events sent so far. It is safe to call on a nil xDiagnostics.
+func (d *xDiagnostics) Print(w io.Writer, x *x11.Client) {
+	keys := x.GetKeyStats()
+	fmt.Fprintf(w, "Time offset: %d ms\n", x.GetTimeOffset())
+	fmt.Fprintf(w, "Key events: %d sent, %d adjusted\n", keys.Sent, keys.Adjusted)
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.measured.IsZero() {
+		fmt.Fprintln(w, "Not measured yet")
+		return
+	}
+	fmt.Fprintf(w, "Last measured: %s\n", d.measured.Format(time.TimeOnly))
+	fmt.Fprintf(w, "Latency: %s\n", d.latency)
+	fmt.Fprintf(w, "Drift: %d ms\n", d.drift)
+}
+
+// measure measures the X server's latency and clock drift once.
+func (d *xDiagnostics) measure() {
+	latency, err := d.x.MeasureLatency()
+	if err != nil {
+		log.Error("Diagnostics: measure latency: %s", err)
+		return
+	}
+	offset, err := d.x.MeasureTimeOffset()
+	if err != nil {
+		log.Error("Diagnostics: measure time offset: %s", err)
+		return
+	}
+	drift := int64(offset) - int64(d.x.GetTimeOffset())
+
+	d.mu.Lock()
+	d.measured = time.Now()
+	d.latency = latency
+	d.drift = drift
+	d.mu.Unlock()
+
+	log.Debug("Diagnostics: latency %s, drift %d ms", latency, drift)
+	if d.conf.MaxLatency > 0 && latency > time.Duration(d.conf.MaxLatency)*time.Millisecond {
+		log.Warn("X server latency is high (%s)", latency)
+	}
+	if d.conf.MaxDrift > 0 && abs(drift) > int64(d.conf.MaxDrift) {
+		if d.conf.Recalibrate {
+			log.Info("X server clock has drifted by %d ms, recalibrating", drift)
+			d.x.SetTimeOffset(offset)
+		} else {
+			log.Warn("X server clock has drifted by %d ms; key presses may be ignored", drift)
+		}
+	}
+}
+
+// recalibrate measures the time offset and starts using it for key events.
+func (d *xDiagnostics) recalibrate() {
+	offset, err := d.x.MeasureTimeOffset()
+	if err != nil {
+		log.Error("Diagnostics: measure time offset: %s", err)
+		return
+	}
+	drift := int64(offset) - int64(d.x.GetTimeOffset())
+	d.x.SetTimeOffset(offset)
+
+	d.mu.Lock()
+	d.measured = time.Now()
+	d.drift = drift
+	d.mu.Unlock()
+	log.Info("Diagnostics: recalibrated time offset (drift %d ms)", drift)
+}
+
+// abs returns the absolute value of n.
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
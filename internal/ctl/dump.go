@@ -0,0 +1,242 @@
+package ctl
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/tesselslate/resetti/internal/cfg"
+	"github.com/tesselslate/resetti/internal/mc"
+	"github.com/tesselslate/resetti/internal/x11"
+	"golang.org/x/exp/slices"
+)
+
+// The maximum amount of each log file to include in a debug dump.
+const dumpLogSize = 256 * 1024
+
+// Profile settings which may contain secrets and are redacted from debug dumps,
+// by table. A table with no keys listed has all of its settings redacted.
+var secretSettings = map[string][]string{
+	"counter": {"url"},
+	"hooks":   nil,
+	"remote":  {"token", "read_token"},
+}
+
+// DebugDump writes an archive (.tar.gz) to the given path containing
+// information which is useful for bug reports: the given profile (with any
+// secrets redacted), information about the system and X server, the detected
+// instances, and the end of the log.
+func DebugDump(path string, profileName string, logPath string, version string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	gz := gzip.NewWriter(file)
+	archive := tar.NewWriter(gz)
+
+	add := func(name string, data []byte) error {
+		err := archive.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = archive.Write(data)
+		return err
+	}
+
+	info := bytes.Buffer{}
+	fmt.Fprintf(&info, "resetti %s\n", strings.TrimSpace(version))
+	fmt.Fprintf(&info, "Go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	dumpSystem(&info)
+	dumpProfile(&info, profileName)
+	dumpX(&info, profileName)
+	if err := add("info.txt", info.Bytes()); err != nil {
+		return err
+	}
+
+	if dir, err := cfg.GetDirectory(); err == nil {
+		if profile, err := os.ReadFile(dir + profileName + ".toml"); err == nil {
+			// A profile which cannot be parsed cannot be redacted, so it is
+			// left out (info.txt still contains the parse error.)
+			if redacted, err := redactProfile(profile); err == nil {
+				if err := add("profile.toml", redacted); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for _, logFile := range []string{logPath, logPath + ".1"} {
+		data, err := readTail(logFile, dumpLogSize)
+		if err != nil {
+			continue
+		}
+		name := strings.TrimPrefix(logFile, logPath)
+		if err := add("resetti.log"+name, data); err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// dumpProfile writes whether or not the given profile is valid.
+func dumpProfile(w io.Writer, profileName string) {
+	fmt.Fprintf(w, "\nProfile: %s\n", profileName)
+	if _, err := cfg.GetProfile(profileName); err != nil {
+		fmt.Fprintf(w, "  Invalid: %s\n", err)
+	} else {
+		fmt.Fprintln(w, "  Valid")
+	}
+}
+
+// redactProfile decodes the given profile, replaces the values of any settings
+// which may contain secrets, and encodes it again. Empty values are kept so
+// that it is still clear which settings are in use.
+func redactProfile(profile []byte) ([]byte, error) {
+	var doc map[string]any
+	if err := toml.Unmarshal(profile, &doc); err != nil {
+		return nil, err
+	}
+	for table, keys := range secretSettings {
+		settings, ok := doc[table].(map[string]any)
+		if !ok {
+			continue
+		}
+		for key, value := range settings {
+			if keys == nil || slices.Contains(keys, key) {
+				settings[key] = redactValue(value)
+			}
+		}
+	}
+	buf := bytes.Buffer{}
+	if err := toml.NewEncoder(&buf).Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// redactValue replaces every non-empty string in the given setting value.
+func redactValue(value any) any {
+	switch value := value.(type) {
+	case string:
+		if value == "" {
+			return value
+		}
+		return "REDACTED"
+	case []any:
+		redacted := make([]any, len(value))
+		for i, item := range value {
+			redacted[i] = redactValue(item)
+		}
+		return redacted
+	case map[string]any:
+		redacted := make(map[string]any, len(value))
+		for key, item := range value {
+			redacted[key] = redactValue(item)
+		}
+		return redacted
+	default:
+		return value
+	}
+}
+
+// dumpSystem writes information about the CPU and kernel.
+func dumpSystem(w io.Writer) {
+	fmt.Fprintf(w, "CPUs: %d\n", runtime.NumCPU())
+	if cpuinfo, err := os.Open("/proc/cpuinfo"); err == nil {
+		scanner := bufio.NewScanner(cpuinfo)
+		for scanner.Scan() {
+			if name, ok := strings.CutPrefix(scanner.Text(), "model name"); ok {
+				fmt.Fprintf(w, "CPU model: %s\n", strings.TrimLeft(name, " \t:"))
+				break
+			}
+		}
+		_ = cpuinfo.Close()
+	}
+	if kernel, err := os.ReadFile("/proc/version"); err == nil {
+		fmt.Fprintf(w, "Kernel: %s", kernel)
+	}
+	for _, env := range []string{"XDG_SESSION_TYPE", "XDG_CURRENT_DESKTOP", "DISPLAY"} {
+		fmt.Fprintf(w, "%s: %s\n", env, os.Getenv(env))
+	}
+}
+
+// dumpX writes information about the X server, window manager, and any
+// Minecraft windows.
+func dumpX(w io.Writer, profileName string) {
+	fmt.Fprintln(w, "\nX server:")
+	x, err := x11.NewClient()
+	if err != nil {
+		fmt.Fprintf(w, "  Failed to connect: %s\n", err)
+		return
+	}
+	if wm, err := x.GetWmName(); err != nil {
+		fmt.Fprintf(w, "  Window manager: unknown (%s)\n", err)
+	} else {
+		fmt.Fprintf(w, "  Window manager: %s\n", wm)
+	}
+
+	fmt.Fprintln(w, "\nMinecraft windows:")
+	for _, win := range x.GetWindowList() {
+		class, err := x.GetWindowClass(win)
+		if err != nil || !strings.Contains(class, "Minecraft") {
+			continue
+		}
+		title, _ := x.GetWindowTitle(win)
+		pid, _ := x.GetWindowPid(win)
+		fmt.Fprintf(w, "  %d: class %q, title %q, pid %d\n", win, class, title, pid)
+	}
+
+	profile, err := cfg.GetProfile(profileName)
+	if err != nil {
+		return
+	}
+	instance, err := mc.FindInstance(&x, &profile.Instance)
+	if err != nil {
+		fmt.Fprintf(w, "\nDetected instance: none (%s)\n", err)
+		return
+	}
+	fmt.Fprintf(w, "\nDetected instance: %+v\n", instance)
+}
+
+// readTail reads up to the last n bytes of the given file.
+func readTail(path string, n int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if stat.Size() > n {
+		if _, err := file.Seek(-n, io.SeekEnd); err != nil {
+			return nil, err
+		}
+	}
+	return io.ReadAll(file)
+}
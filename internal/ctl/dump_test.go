@@ -0,0 +1,66 @@
+package ctl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+func TestRedactProfile(t *testing.T) {
+	profile := `
+poll_rate = 100
+counter = { url = "https://secret@example.com", file = "count.txt" }
+
+[hooks]
+reset = "curl -H 'Authorization: secret' example.com"
+alt_res = ["notify-send secret", ""]
+exit = """
+echo secret
+"""
+
+[remote]
+address = "localhost:7777"
+token = "secret"
+read_token = ""
+
+[keybinds]
+"ctrl-f" = "ingame_reset"
+`
+	want := map[string]any{
+		"poll_rate": int64(100),
+		"hooks": map[string]any{
+			"reset":   "REDACTED",
+			"alt_res": []any{"REDACTED", ""},
+			"exit":    "REDACTED",
+		},
+		"remote": map[string]any{
+			"address":    "localhost:7777",
+			"token":      "REDACTED",
+			"read_token": "",
+		},
+		"counter": map[string]any{
+			"url":  "REDACTED",
+			"file": "count.txt",
+		},
+		"keybinds": map[string]any{
+			"ctrl-f": "ingame_reset",
+		},
+	}
+
+	redacted, err := redactProfile([]byte(profile))
+	if err != nil {
+		t.Fatalf("redact profile: %s", err)
+	}
+	var got map[string]any
+	if err := toml.Unmarshal(redacted, &got); err != nil {
+		t.Fatalf("parse redacted profile: %s\n%s", err, redacted)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := redactProfile([]byte("token = ")); err == nil {
+		t.Error("invalid profile was redacted")
+	}
+}
@@ -0,0 +1,96 @@
+package ctl
+
+// An event is something which happened to the instance. Events are published
+// by the Controller and delivered to every subscriber on the event bus.
+type event any
+
+// focusEvent is published when the instance gains or loses focus.
+type focusEvent struct {
+	Dir     string
+	Focused bool
+}
+
+// resetEvent is published after the instance is reset.
+type resetEvent struct {
+	Dir string
+}
+
+// resolutionEvent is published after the instance's resolution is toggled.
+type resolutionEvent struct {
+	Dir    string
+	AltRes bool // Whether the instance is now using an alternate resolution
+	Res    int  // Alternate resolution ID
+}
+
+// A subscriber receives events from an eventBus.
+type subscriber interface {
+	// HandleEvent processes a single event. It is called from the
+	// Controller's goroutine, so it should not block.
+	HandleEvent(evt event)
+}
+
+// eventBus delivers events to all of the Controller's subsystems, so that
+// the Controller does not need to notify each of them separately.
+type eventBus struct {
+	subscribers []subscriber
+}
+
+// Publish delivers an event to every subscriber, in the order in which they
+// subscribed.
+func (b *eventBus) Publish(evt event) {
+	for _, sub := range b.subscribers {
+		sub.HandleEvent(evt)
+	}
+}
+
+// Subscribe adds a subscriber to the bus.
+func (b *eventBus) Subscribe(sub subscriber) {
+	b.subscribers = append(b.subscribers, sub)
+}
+
+// HandleEvent implements subscriber.
+func (l *livesplitClient) HandleEvent(evt event) {
+	if _, ok := evt.(resetEvent); ok {
+		l.Reset()
+	}
+}
+
+// HandleEvent implements subscriber.
+func (r *remoteServer) HandleEvent(evt event) {
+	switch evt := evt.(type) {
+	case focusEvent:
+		r.Broadcast(remoteEvent{Type: eventFocus, Instance: evt.Dir, Focused: evt.Focused})
+	case resetEvent:
+		r.Broadcast(remoteEvent{Type: eventReset, Instance: evt.Dir})
+	case resolutionEvent:
+		r.Broadcast(remoteEvent{
+			Type:     eventResolution,
+			Instance: evt.Dir,
+			AltRes:   evt.AltRes,
+			Res:      evt.Res,
+		})
+	}
+}
+
+// HandleEvent implements subscriber.
+func (c *resetCounter) HandleEvent(evt event) {
+	if _, ok := evt.(resetEvent); ok {
+		c.Increment()
+	}
+}
+
+// HandleEvent implements subscriber.
+func (s *statsTracker) HandleEvent(evt event) {
+	switch evt := evt.(type) {
+	case focusEvent:
+		if evt.Focused {
+			s.Focus(evt.Dir)
+		} else {
+			s.Focus("")
+		}
+	case resetEvent:
+		s.Reset(evt.Dir)
+	case resolutionEvent:
+		s.ToggleResolution(evt.Dir)
+	}
+}
@@ -0,0 +1,129 @@
+package ctl
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jezek/xgb/xproto"
+	"github.com/tesselslate/resetti/internal/cfg"
+	"github.com/tesselslate/resetti/internal/x11"
+	"golang.org/x/exp/slices"
+)
+
+// CaptureKeys prints the keybind string for every key combination the user
+// presses, along with a warning if the combination is already grabbed by
+// another program (such as the window manager.) It runs until resetti is
+// killed.
+//
+// If a profile is given and it has layout_binds enabled, the printed names are
+// resolved with the current keyboard layout, as they would be when resetti
+// reads the profile.
+func CaptureKeys(w io.Writer, profileName string) error {
+	x, err := x11.NewClient()
+	if err != nil {
+		return fmt.Errorf("create X client: %w", err)
+	}
+	keycodes, modifiers := x11.Keycodes, x11.Modifiers
+	if profileName != "" {
+		profile, err := cfg.GetProfile(profileName)
+		if err != nil {
+			return fmt.Errorf("get profile: %w", err)
+		}
+		if profile.LayoutBinds {
+			keysyms, err := x.GetKeysymMapping()
+			if err != nil {
+				return fmt.Errorf("get keyboard mapping: %w", err)
+			}
+			keycodes = layoutNames(keycodes, keysyms)
+			modifiers = layoutNames(modifiers, keysyms)
+		}
+	}
+	keyNames := canonicalNames(keycodes)
+	modNames := canonicalNames(modifiers)
+
+	fmt.Fprintln(w, "Press a key combination to see its keybind. Press Ctrl+C to exit.")
+	var last []xproto.Keycode
+	for {
+		time.Sleep(10 * time.Millisecond)
+		keymap, err := x.QueryKeymap()
+		if err != nil {
+			return fmt.Errorf("query keymap: %w", err)
+		}
+		pressed := keymap.Pressed()
+		var mods []xproto.Keycode
+		var keys []xproto.Keycode
+		for _, code := range pressed {
+			if _, ok := modNames[code]; ok {
+				mods = append(mods, code)
+			} else {
+				keys = append(keys, code)
+			}
+		}
+		for _, key := range keys {
+			if slices.Contains(last, key) {
+				continue
+			}
+			var parts []string
+			for _, mod := range mods {
+				parts = append(parts, modNames[mod])
+			}
+			if name, ok := keyNames[key]; ok {
+				parts = append(parts, name)
+			} else {
+				parts = append(parts, fmt.Sprintf("code%d", key))
+			}
+			bind := strings.Join(parts, "-")
+			grabbed, err := x.IsKeyGrabbed(key, mods)
+			switch {
+			case err != nil:
+				fmt.Fprintf(w, "%q (could not check for grabs: %s)\n", bind, err)
+			case grabbed:
+				fmt.Fprintf(w, "%q (already grabbed by another program)\n", bind)
+			default:
+				fmt.Fprintf(w, "%q\n", bind)
+			}
+		}
+		last = pressed
+	}
+}
+
+// layoutNames returns a copy of the given name mapping with each name moved to
+// the keycode which produces its keysym in the given keyboard layout. Names
+// without a keysym in the layout keep their US keycode, as in cfg.Bind.
+func layoutNames(names map[string]xproto.Keycode, keysyms map[xproto.Keysym]xproto.Keycode) map[string]xproto.Keycode {
+	resolved := make(map[string]xproto.Keycode, len(names))
+	for name, code := range names {
+		resolved[name] = code
+		if sym, ok := x11.Keysyms[name]; ok {
+			if layoutCode, ok := keysyms[sym]; ok {
+				resolved[name] = layoutCode
+			}
+		}
+	}
+	return resolved
+}
+
+// canonicalNames returns a mapping of keycodes to names. If several names map
+// to the same keycode, the shortest one (or first alphabetically) is used.
+func canonicalNames(names map[string]xproto.Keycode) map[xproto.Keycode]string {
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i]) != len(sorted[j]) {
+			return len(sorted[i]) < len(sorted[j])
+		}
+		return sorted[i] < sorted[j]
+	})
+	codes := make(map[xproto.Keycode]string, len(names))
+	for _, name := range sorted {
+		if _, ok := codes[names[name]]; !ok {
+			codes[names[name]] = name
+		}
+	}
+	return codes
+}
@@ -0,0 +1,82 @@
+package ctl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jezek/xgb/xproto"
+	"github.com/tesselslate/resetti/internal/x11"
+)
+
+func TestCanonicalNames(t *testing.T) {
+	tests := []struct {
+		name  string
+		names map[string]xproto.Keycode
+		want  map[xproto.Keycode]string
+	}{
+		{
+			name:  "empty",
+			names: map[string]xproto.Keycode{},
+			want:  map[xproto.Keycode]string{},
+		},
+		{
+			name:  "distinct",
+			names: map[string]xproto.Keycode{"a": 38, "b": 56},
+			want:  map[xproto.Keycode]string{38: "a", 56: "b"},
+		},
+		{
+			name:  "shortest",
+			names: map[string]xproto.Keycode{"escape": 9, "esc": 9},
+			want:  map[xproto.Keycode]string{9: "esc"},
+		},
+		{
+			name:  "alphabetical",
+			names: map[string]xproto.Keycode{"lctrl": 37, "ctrl": 37, "lctl": 37},
+			want:  map[xproto.Keycode]string{37: "ctrl"},
+		},
+		{
+			name:  "same length",
+			names: map[string]xproto.Keycode{"grave": 49, "tilde": 49},
+			want:  map[xproto.Keycode]string{49: "grave"},
+		},
+	}
+	for _, tt := range tests {
+		if got := canonicalNames(tt.names); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLayoutNames(t *testing.T) {
+	// A German layout, where the Y and Z keys are swapped.
+	keysyms := map[xproto.Keysym]xproto.Keycode{
+		x11.Keysyms["y"]: 52,
+		x11.Keysyms["z"]: 29,
+	}
+	tests := []struct {
+		name  string
+		names map[string]xproto.Keycode
+		want  map[string]xproto.Keycode
+	}{
+		{
+			name:  "swapped",
+			names: map[string]xproto.Keycode{"y": 29, "z": 52},
+			want:  map[string]xproto.Keycode{"y": 52, "z": 29},
+		},
+		{
+			name:  "not in layout",
+			names: map[string]xproto.Keycode{"a": 38},
+			want:  map[string]xproto.Keycode{"a": 38},
+		},
+		{
+			name:  "no keysym",
+			names: map[string]xproto.Keycode{"unknown": 200},
+			want:  map[string]xproto.Keycode{"unknown": 200},
+		},
+	}
+	for _, tt := range tests {
+		if got := layoutNames(tt.names, keysyms); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package ctl
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/tesselslate/resetti/internal/cfg"
+	"github.com/tesselslate/resetti/internal/log"
+)
+
+// LiveSplit Server commands
+const (
+	livesplitReset = "reset"
+	livesplitStart = "starttimer"
+)
+
+// livesplitClient sends commands to a LiveSplit Server (or any other timer
+// which implements its TCP protocol, such as livesplit-one.) Commands are sent
+// in the background so that a slow or missing timer never blocks the
+// controller.
+type livesplitClient struct {
+	conf *cfg.LiveSplit
+	cmds chan string
+}
+
+// newLivesplitClient creates a new livesplitClient for the given settings.
+func newLivesplitClient(conf *cfg.LiveSplit) *livesplitClient {
+	return &livesplitClient{conf, make(chan string, 16)}
+}
+
+// Run sends commands to the timer until the context is cancelled, connecting
+// (and reconnecting) as needed.
+func (l *livesplitClient) Run(ctx context.Context) {
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			_ = conn.Close()
+		}
+	}()
+	dialer := net.Dialer{Timeout: time.Second}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case cmd := <-l.cmds:
+			// Try to send the command twice, in case the old connection was
+			// closed by the timer.
+			for attempt := 0; attempt < 2; attempt += 1 {
+				if conn == nil {
+					var err error
+					conn, err = dialer.DialContext(ctx, "tcp", l.conf.Address)
+					if err != nil {
+						log.Warn("LiveSplit: connect failed: %s", err)
+						break
+					}
+				}
+				_ = conn.SetWriteDeadline(time.Now().Add(time.Second))
+				if _, err := conn.Write([]byte(cmd + "\r\n")); err != nil {
+					log.Warn("LiveSplit: send %q failed: %s", cmd, err)
+					_ = conn.Close()
+					conn = nil
+					continue
+				}
+				break
+			}
+		}
+	}
+}
+
+// Reset resets the timer and starts it again if configured to do so. It is
+// safe to call on a nil livesplitClient.
+func (l *livesplitClient) Reset() {
+	if l == nil {
+		return
+	}
+	l.send(livesplitReset)
+	if l.conf.StartOnReset {
+		l.send(livesplitStart)
+	}
+}
+
+// send queues a command to be sent to the timer. If the queue is full, the
+// command is dropped.
+func (l *livesplitClient) send(cmd string) {
+	select {
+	case l.cmds <- cmd:
+	default:
+		log.Warn("LiveSplit: command queue full, dropped %q", cmd)
+	}
+}
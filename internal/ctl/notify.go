@@ -0,0 +1,97 @@
+package ctl
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/tesselslate/resetti/internal/cfg"
+	"github.com/tesselslate/resetti/internal/log"
+)
+
+// Notification kinds
+const (
+	notifyInstanceCrashed = "instance_crashed"
+	notifyInstanceDied    = "instance_died"
+	notifyInstanceStuck   = "instance_stuck"
+	notifyPanic           = "panic"
+	notifyXError          = "x_error"
+)
+
+// How long to wait for a notification to be sent before resetti exits.
+const notifyTimeout = 2 * time.Second
+
+// notifier shows desktop notifications for important events. Notifications of
+// the same kind are throttled so that the user is not spammed.
+type notifier struct {
+	throttle time.Duration
+	sender   string // Path to notify-send (empty if disabled or unavailable.)
+
+	// The mutex guards the last notification times.
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+// newNotifier creates a new notifier with the settings from the given
+// configuration profile.
+func newNotifier(conf *cfg.Profile) *notifier {
+	n := &notifier{
+		throttle: time.Duration(conf.Notifications.Throttle) * time.Second,
+		last:     make(map[string]time.Time),
+	}
+	if !conf.Notifications.Enabled {
+		return n
+	}
+	path, err := exec.LookPath("notify-send")
+	if err != nil {
+		log.Warn("Notifications are enabled but notify-send could not be found: %s", err)
+	}
+	n.sender = path
+	return n
+}
+
+// Notify shows a desktop notification unless one of the same kind was shown
+// recently.
+func (n *notifier) Notify(kind string, summary string, body string) {
+	cmd := n.command(context.Background(), kind, summary, body)
+	if cmd == nil {
+		return
+	}
+	go func() {
+		if err := cmd.Run(); err != nil {
+			log.Error("Notify (%s) failed: %s", kind, err)
+		}
+	}()
+}
+
+// NotifyWait is like Notify, but it waits (for up to notifyTimeout) for the
+// notification to be sent. It is used when resetti is about to exit, which
+// could otherwise happen before notify-send runs.
+func (n *notifier) NotifyWait(kind string, summary string, body string) {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	cmd := n.command(ctx, kind, summary, body)
+	if cmd == nil {
+		return
+	}
+	if err := cmd.Run(); err != nil {
+		log.Error("Notify (%s) failed: %s", kind, err)
+	}
+}
+
+// command returns the notify-send command for a notification, or nil if
+// notifications are disabled or one of the same kind was shown recently.
+func (n *notifier) command(ctx context.Context, kind string, summary string, body string) *exec.Cmd {
+	if n.sender == "" {
+		return nil
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	now := time.Now()
+	if last, ok := n.last[kind]; ok && now.Sub(last) < n.throttle {
+		return nil
+	}
+	n.last[kind] = now
+	return exec.CommandContext(ctx, n.sender, "--app-name=resetti", summary, body)
+}
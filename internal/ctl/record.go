@@ -0,0 +1,109 @@
+package ctl
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/tesselslate/resetti/internal/cfg"
+	"github.com/tesselslate/resetti/internal/log"
+)
+
+// recordedInput is a single user input stored in a recording. Recordings are
+// stored as one JSON object per line.
+type recordedInput struct {
+	Time time.Duration `json:"time"` // Time since the recording started
+	Bind string        `json:"bind"`
+	Held bool          `json:"held"`
+	X    int           `json:"x"`
+	Y    int           `json:"y"`
+}
+
+// inputRecorder writes all of the user's inputs to a file so that they can be
+// replayed later.
+type inputRecorder struct {
+	file  *os.File
+	start time.Time
+}
+
+// newInputRecorder creates a new inputRecorder which writes to the given path.
+func newInputRecorder(path string) (*inputRecorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &inputRecorder{file, time.Now()}, nil
+}
+
+// Close closes the recording file. It is safe to call on a nil inputRecorder.
+func (r *inputRecorder) Close() {
+	if r == nil {
+		return
+	}
+	if err := r.file.Close(); err != nil {
+		log.Error("Close input recording failed: %s", err)
+	}
+}
+
+// Record writes an input to the recording. It is safe to call on a nil
+// inputRecorder.
+func (r *inputRecorder) Record(input Input) {
+	if r == nil {
+		return
+	}
+	data, err := json.Marshal(recordedInput{
+		time.Since(r.start),
+		input.Bind.String(),
+		input.Held,
+		input.X,
+		input.Y,
+	})
+	if err != nil {
+		log.Error("Record input failed: %s", err)
+		return
+	}
+	if _, err := r.file.Write(append(data, '\n')); err != nil {
+		log.Error("Record input failed: %s", err)
+	}
+}
+
+// replayInputs reads a recording and submits its inputs with the same timing
+// as they were recorded.
+func replayInputs(ctx context.Context, path string, binds cfg.Keybinds, inputs chan<- Input) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	bindNames := make(map[string]cfg.Bind, len(binds))
+	for bind := range binds {
+		bind := bind
+		bindNames[bind.String()] = bind
+	}
+
+	start := time.Now()
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line += 1 {
+		var rec recordedInput
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return fmt.Errorf("parse line %d: %w", line, err)
+		}
+		bind, ok := bindNames[rec.Bind]
+		if !ok {
+			return fmt.Errorf("line %d: keybind %q is not in the profile", line, rec.Bind)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Until(start.Add(rec.Time))):
+		}
+		inputs <- Input{bind, rec.Held, rec.X, rec.Y}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,319 @@
+package ctl
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tesselslate/resetti/internal/cfg"
+	"github.com/tesselslate/resetti/internal/log"
+	"github.com/tesselslate/resetti/internal/res"
+	"github.com/tesselslate/resetti/internal/ws"
+)
+
+// Remote control actions
+const (
+	remoteReset     = "reset"
+	remoteFocus     = "focus"
+	remoteToggleRes = "toggle_res"
+)
+
+// Remote control event types
+const (
+	eventReset      = "reset"
+	eventFocus      = "focus"
+	eventResolution = "resolution"
+)
+
+// The number of events which can be queued for a WebSocket client before it is
+// considered too slow and disconnected.
+const remoteSendBuffer = 32
+
+// How long a write to a WebSocket client may take before it is abandoned.
+const remoteWriteTimeout = 5 * time.Second
+
+// Levels of access granted to remote control clients
+const (
+	accessNone = iota
+	accessRead
+	accessFull
+)
+
+// remoteCommand is an action requested by a remote control client.
+type remoteCommand struct {
+	Action string `json:"action"`
+	Res    int    `json:"res"` // Alternate resolution ID (toggle_res only)
+}
+
+// remoteEvent is an event broadcast to all remote control clients.
+type remoteEvent struct {
+	Type     string `json:"type"`
+	Instance string `json:"instance"`
+	Focused  bool   `json:"focused,omitempty"` // focus only
+	AltRes   bool   `json:"alt_res,omitempty"` // resolution only
+	Res      int    `json:"res,omitempty"`     // resolution only
+}
+
+// remoteSnapshot is the state of resetti returned by the /state endpoint.
+// Fields may be added in the future, but existing fields will not be removed
+// or renamed without incrementing the schema version.
+type remoteSnapshot struct {
+	Schema      int                `json:"schema"`      // Schema version
+	Instances   []instanceSnapshot `json:"instances"`   // Managed instances
+	Active      int                `json:"active"`      // Index of the focused instance (-1 if none)
+	Resolutions []string           `json:"resolutions"` // Names (or sizes) of the alternate resolutions
+	Counts      resetCounts        `json:"counts"`      // Reset counts from the counter
+	Session     SessionStats       `json:"session"`     // Current session statistics
+}
+
+// instanceSnapshot is the state of a single instance within a remoteSnapshot.
+type instanceSnapshot struct {
+	Dir     string `json:"dir"`     // .minecraft directory
+	Pid     uint32 `json:"pid"`     // Process ID
+	Window  uint32 `json:"window"`  // X window ID
+	Version int    `json:"version"` // Minecraft version
+	AltRes  bool   `json:"alt_res"` // Using an alternate resolution
+	Resets  int    `json:"resets"`  // Resets this session
+}
+
+// remoteServer exposes the Controller's actions to external tools over HTTP
+// and WebSocket, and broadcasts events to any connected WebSocket clients.
+type remoteServer struct {
+	conf     *cfg.Remote
+	cmds     chan<- remoteCommand
+	snapshot func() remoteSnapshot
+
+	// The mutex guards the list of connected clients.
+	mu      sync.Mutex
+	clients map[*remoteClient]struct{}
+}
+
+// remoteClient is a connected WebSocket client. Events are queued for it and
+// written by a separate goroutine, so that a slow client cannot block the
+// Controller.
+type remoteClient struct {
+	conn *ws.Conn
+	send chan []byte
+	once sync.Once
+}
+
+// newRemoteServer creates a new remoteServer which submits commands to the
+// given channel and serves state snapshots from the given function.
+func newRemoteServer(conf *cfg.Remote, cmds chan<- remoteCommand, snapshot func() remoteSnapshot) *remoteServer {
+	return &remoteServer{
+		conf:     conf,
+		cmds:     cmds,
+		snapshot: snapshot,
+		clients:  make(map[*remoteClient]struct{}),
+	}
+}
+
+// Run starts listening for remote control clients until the context is
+// cancelled. Any errors are logged.
+func (r *remoteServer) Run(ctx context.Context) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", r.handleDashboard)
+	mux.HandleFunc("/action", r.handleAction)
+	mux.HandleFunc("/state", r.handleState)
+	mux.HandleFunc("/ws", r.handleWebsocket)
+	server := &http.Server{
+		Addr:        r.conf.Address,
+		Handler:     mux,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for client := range r.clients {
+			client.stop()
+			delete(r.clients, client)
+		}
+	}()
+
+	log.Info("Remote control server listening on %s", r.conf.Address)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Error("Remote control server failed: %s", err)
+	}
+}
+
+// Broadcast queues an event to be sent to all connected WebSocket clients.
+// Clients which have fallen too far behind are disconnected. It never blocks,
+// and it is safe to call on a nil remoteServer.
+func (r *remoteServer) Broadcast(evt remoteEvent) {
+	if r == nil {
+		return
+	}
+	data, err := json.Marshal(evt)
+	if err != nil {
+		log.Error("Remote broadcast: marshal failed: %s", err)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for client := range r.clients {
+		select {
+		case client.send <- data:
+		default:
+			log.Warn("Remote broadcast: client is too slow, disconnecting")
+			client.stop()
+			delete(r.clients, client)
+		}
+	}
+}
+
+// access determines the level of access granted by the token in the request,
+// given either as a bearer token or a "token" query parameter.
+func (r *remoteServer) access(req *http.Request) int {
+	token := req.URL.Query().Get("token")
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	switch {
+	case subtle.ConstantTimeCompare([]byte(token), []byte(r.conf.Token)) == 1:
+		return accessFull
+	case r.conf.ReadToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(r.conf.ReadToken)) == 1:
+		return accessRead
+	default:
+		return accessNone
+	}
+}
+
+// handleAction handles a single action sent via an HTTP POST request.
+func (r *remoteServer) handleAction(w http.ResponseWriter, req *http.Request) {
+	switch r.access(req) {
+	case accessNone:
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	case accessRead:
+		http.Error(w, "read-only token", http.StatusForbidden)
+		return
+	}
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var cmd remoteCommand
+	if err := json.NewDecoder(req.Body).Decode(&cmd); err != nil {
+		http.Error(w, "invalid command", http.StatusBadRequest)
+		return
+	}
+	select {
+	case r.cmds <- cmd:
+		w.WriteHeader(http.StatusAccepted)
+	case <-req.Context().Done():
+	}
+}
+
+// handleDashboard serves the web dashboard. The dashboard itself does not
+// require the token, but every request it makes does.
+func (r *remoteServer) handleDashboard(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.NotFound(w, req)
+		return
+	}
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := w.Write(res.Dashboard); err != nil {
+		log.Warn("Remote: write dashboard failed: %s", err)
+	}
+}
+
+// handleState returns a JSON snapshot of resetti's current state.
+func (r *remoteServer) handleState(w http.ResponseWriter, req *http.Request) {
+	if r.access(req) == accessNone {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(r.snapshot()); err != nil {
+		log.Warn("Remote: write state failed: %s", err)
+	}
+}
+
+// handleWebsocket accepts a WebSocket client, which receives all broadcast
+// events and can send actions unless it connected with the read-only token.
+func (r *remoteServer) handleWebsocket(w http.ResponseWriter, req *http.Request) {
+	access := r.access(req)
+	if access == accessNone {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	conn, err := ws.Upgrade(w, req)
+	if err != nil {
+		log.Warn("Remote: websocket upgrade failed: %s", err)
+		return
+	}
+	client := &remoteClient{conn: conn, send: make(chan []byte, remoteSendBuffer)}
+	go client.writeLoop()
+	r.mu.Lock()
+	r.clients[client] = struct{}{}
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.clients, client)
+		r.mu.Unlock()
+		client.stop()
+	}()
+
+	for {
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if access != accessFull {
+			log.Warn("Remote: ignoring command from read-only websocket client")
+			continue
+		}
+		var cmd remoteCommand
+		if err := json.Unmarshal(msg, &cmd); err != nil {
+			log.Warn("Remote: invalid command from websocket client: %s", err)
+			continue
+		}
+		select {
+		case r.cmds <- cmd:
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// stop stops sending events to the client. The connection is closed once any
+// queued events have been written (or a write fails.) The client must be
+// removed from the remoteServer's list of clients under the same lock (or
+// before), so that Broadcast never queues an event after it is stopped.
+func (c *remoteClient) stop() {
+	c.once.Do(func() {
+		close(c.send)
+	})
+}
+
+// writeLoop writes queued events to the client until it is stopped or a write
+// fails, and then closes the connection.
+func (c *remoteClient) writeLoop() {
+	defer func() {
+		_ = c.conn.Close()
+	}()
+	for data := range c.send {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(remoteWriteTimeout))
+		if err := c.conn.WriteMessage(data); err != nil {
+			log.Warn("Remote broadcast: write failed: %s", err)
+			return
+		}
+	}
+}
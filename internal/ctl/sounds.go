@@ -0,0 +1,67 @@
+package ctl
+
+import (
+	"os/exec"
+	"strconv"
+
+	"github.com/tesselslate/resetti/internal/cfg"
+	"github.com/tesselslate/resetti/internal/log"
+)
+
+// The maximum volume accepted by paplay (100%).
+const paVolumeNorm = 65536
+
+// soundPlayer plays audio cues through PulseAudio (or PipeWire's PulseAudio
+// compatibility layer) whenever hooks are run.
+type soundPlayer struct {
+	sounds map[int]cfg.Sound
+	player string // Path to paplay (empty if unavailable.)
+}
+
+// newSoundPlayer creates a new soundPlayer with the sounds from the given
+// configuration profile.
+func newSoundPlayer(conf *cfg.Profile) *soundPlayer {
+	s := &soundPlayer{
+		sounds: map[int]cfg.Sound{
+			HookReset:       conf.Sounds.Reset,
+			HookAltRes:      conf.Sounds.AltRes,
+			HookNormalRes:   conf.Sounds.NormalRes,
+			HookFocusLost:   conf.Sounds.FocusLost,
+			HookFocusGained: conf.Sounds.FocusGained,
+		},
+	}
+	for _, sound := range s.sounds {
+		if sound.File == "" {
+			continue
+		}
+		path, err := exec.LookPath("paplay")
+		if err != nil {
+			log.Warn("Sounds are configured but paplay could not be found: %s", err)
+		}
+		s.player = path
+		break
+	}
+	return s
+}
+
+// Play plays the sound for the given hook type, if there is one.
+func (s *soundPlayer) Play(hook int) {
+	sound := s.sounds[hook]
+	if sound.File == "" || s.player == "" {
+		return
+	}
+	volume := sound.Volume
+	if volume == 0 {
+		volume = 1
+	}
+	cmd := exec.Command(
+		s.player,
+		"--volume="+strconv.Itoa(int(volume*paVolumeNorm)),
+		sound.File,
+	)
+	go func() {
+		if err := cmd.Run(); err != nil {
+			log.Error("Play sound %q failed: %s", sound.File, err)
+		}
+	}()
+}
@@ -0,0 +1,234 @@
+package ctl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tesselslate/resetti/internal/res"
+)
+
+// statsFile is the path of the statistics file within the data directory.
+const statsFile = "/stats.json"
+
+// InstanceStats contains the statistics recorded for a single instance during
+// a session.
+type InstanceStats struct {
+	Resets     int           `json:"resets"`      // Successful resets
+	ResToggles int           `json:"res_toggles"` // Resolution toggles
+	Focuses    int           `json:"focuses"`     // Times the instance gained focus
+	FocusTime  time.Duration `json:"focus_time"`  // Total time spent focused
+}
+
+// SessionStats contains the statistics recorded during a single session, from
+// the moment resetti started to the moment it exited.
+type SessionStats struct {
+	Start     time.Time                 `json:"start"`
+	End       time.Time                 `json:"end"`
+	Instances map[string]*InstanceStats `json:"instances"` // Keyed by instance directory
+}
+
+// statsHistory is the on-disk format of the statistics file.
+type statsHistory struct {
+	Sessions []SessionStats `json:"sessions"`
+}
+
+// statsTracker records statistics about the current session and writes them
+// to the statistics file on exit.
+type statsTracker struct {
+	mu sync.Mutex
+
+	session    SessionStats
+	focused    string    // Directory of the focused instance (if any.)
+	focusStart time.Time // When the focused instance gained focus.
+}
+
+// newStatsTracker creates a new statsTracker for a session starting now.
+func newStatsTracker() *statsTracker {
+	return &statsTracker{
+		session: SessionStats{
+			Start:     time.Now(),
+			Instances: make(map[string]*InstanceStats),
+		},
+	}
+}
+
+// PrintStats prints a summary of all recorded sessions to the given writer.
+func PrintStats(w io.Writer) error {
+	history, err := readStats()
+	if err != nil {
+		return err
+	}
+	if len(history.Sessions) == 0 {
+		_, err := fmt.Fprintln(w, "No sessions recorded.")
+		return err
+	}
+	total := SessionStats{Instances: make(map[string]*InstanceStats)}
+	for i, session := range history.Sessions {
+		fmt.Fprintf(w, "Session %d: %s\n", i+1, session.Start.Format(time.DateTime))
+		printSession(w, session)
+		for dir, inst := range session.Instances {
+			if total.Instances[dir] == nil {
+				total.Instances[dir] = &InstanceStats{}
+			}
+			total.Instances[dir].add(inst)
+		}
+		total.End = total.End.Add(session.End.Sub(session.Start))
+	}
+	fmt.Fprintln(w, "Total:")
+	printSession(w, total)
+	return nil
+}
+
+// printSession prints the summary of a single session.
+func printSession(w io.Writer, session SessionStats) {
+	length := session.End.Sub(session.Start)
+	resets := 0
+	for _, inst := range session.Instances {
+		resets += inst.Resets
+	}
+	fmt.Fprintf(w, "  Length: %s\n", length.Round(time.Second))
+	if hours := length.Hours(); hours > 0 {
+		fmt.Fprintf(w, "  Resets: %d (%.1f/hour)\n", resets, float64(resets)/hours)
+	} else {
+		fmt.Fprintf(w, "  Resets: %d\n", resets)
+	}
+
+	dirs := make([]string, 0, len(session.Instances))
+	for dir := range session.Instances {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		inst := session.Instances[dir]
+		fmt.Fprintf(
+			w,
+			"  %s: %d resets, %d resolution toggles, %s focused\n",
+			dir,
+			inst.Resets,
+			inst.ResToggles,
+			inst.FocusTime.Round(time.Second),
+		)
+	}
+}
+
+// readStats reads the statistics file. If it does not exist, an empty history
+// is returned.
+func readStats() (statsHistory, error) {
+	history := statsHistory{}
+	buf, err := os.ReadFile(res.GetDataDirectory() + statsFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return history, nil
+		}
+		return history, fmt.Errorf("read stats: %w", err)
+	}
+	if err := json.Unmarshal(buf, &history); err != nil {
+		return history, fmt.Errorf("parse stats: %w", err)
+	}
+	return history, nil
+}
+
+// add adds the counts from another InstanceStats to this one.
+func (s *InstanceStats) add(other *InstanceStats) {
+	s.Resets += other.Resets
+	s.ResToggles += other.ResToggles
+	s.Focuses += other.Focuses
+	s.FocusTime += other.FocusTime
+}
+
+// Focus records a focus change. dir should be the directory of the newly
+// focused instance, or an empty string if no instance is focused.
+func (s *statsTracker) Focus(dir string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.focused == dir {
+		return
+	}
+	now := time.Now()
+	if s.focused != "" {
+		s.get(s.focused).FocusTime += now.Sub(s.focusStart)
+	}
+	if dir != "" {
+		s.get(dir).Focuses += 1
+	}
+	s.focused = dir
+	s.focusStart = now
+}
+
+// Reset records a successful reset of the given instance.
+func (s *statsTracker) Reset(dir string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.get(dir).Resets += 1
+}
+
+// ToggleResolution records a resolution toggle on the given instance.
+func (s *statsTracker) ToggleResolution(dir string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.get(dir).ResToggles += 1
+}
+
+// Resets returns the number of resets of the given instance this session.
+func (s *statsTracker) Resets(dir string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if inst, ok := s.session.Instances[dir]; ok {
+		return inst.Resets
+	}
+	return 0
+}
+
+// Snapshot returns a copy of the current session's statistics.
+func (s *statsTracker) Snapshot() SessionStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session := SessionStats{
+		Start:     s.session.Start,
+		End:       time.Now(),
+		Instances: make(map[string]*InstanceStats, len(s.session.Instances)),
+	}
+	for dir, inst := range s.session.Instances {
+		copied := *inst
+		if dir == s.focused {
+			copied.FocusTime += session.End.Sub(s.focusStart)
+		}
+		session.Instances[dir] = &copied
+	}
+	return session
+}
+
+// Save appends the current session to the statistics file.
+func (s *statsTracker) Save() error {
+	s.Focus("")
+	history, err := readStats()
+	if err != nil {
+		return err
+	}
+	history.Sessions = append(history.Sessions, s.Snapshot())
+	buf, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal stats: %w", err)
+	}
+	if err := os.WriteFile(res.GetDataDirectory()+statsFile, buf, 0644); err != nil {
+		return fmt.Errorf("write stats: %w", err)
+	}
+	return nil
+}
+
+// get returns the statistics for the given instance, creating them if needed.
+// The caller must hold the mutex.
+func (s *statsTracker) get(dir string) *InstanceStats {
+	inst, ok := s.session.Instances[dir]
+	if !ok {
+		inst = &InstanceStats{}
+		s.session.Instances[dir] = inst
+	}
+	return inst
+}
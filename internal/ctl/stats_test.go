@@ -0,0 +1,152 @@
+package ctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/tesselslate/resetti/internal/res"
+)
+
+// useDataDirectory points the data directory at a new temporary directory.
+func useDataDirectory(t *testing.T) {
+	t.Helper()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	if err := res.WriteResources(); err != nil {
+		t.Fatalf("write resources: %s", err)
+	}
+}
+
+func TestStatsTracker(t *testing.T) {
+	s := newStatsTracker()
+	s.Reset("a")
+	s.Reset("a")
+	s.Reset("b")
+	s.ToggleResolution("b")
+	s.Focus("a")
+	s.Focus("a")
+	s.Focus("b")
+	s.focusStart = time.Now().Add(-time.Minute)
+
+	if got := s.Resets("a"); got != 2 {
+		t.Errorf("resets of a: got %d, want 2", got)
+	}
+	if got := s.Resets("c"); got != 0 {
+		t.Errorf("resets of c: got %d, want 0", got)
+	}
+
+	snapshot := s.Snapshot()
+	a, b := snapshot.Instances["a"], snapshot.Instances["b"]
+	if a.Resets != 2 || a.ResToggles != 0 || a.Focuses != 1 {
+		t.Errorf("a: got %+v", a)
+	}
+	if b.Resets != 1 || b.ResToggles != 1 || b.Focuses != 1 {
+		t.Errorf("b: got %+v", b)
+	}
+	if b.FocusTime < time.Minute {
+		t.Errorf("b: focus time is %s, want at least 1m", b.FocusTime)
+	}
+
+	// The snapshot is a copy, and the focus time of the focused instance is
+	// only added to it.
+	b.Resets = 100
+	if got := s.Resets("b"); got != 1 {
+		t.Errorf("resets of b after changing snapshot: got %d, want 1", got)
+	}
+	if got := s.session.Instances["b"].FocusTime; got != 0 {
+		t.Errorf("b: tracked focus time is %s, want 0", got)
+	}
+}
+
+func TestSaveStats(t *testing.T) {
+	useDataDirectory(t)
+	for i := 1; i <= 2; i += 1 {
+		s := newStatsTracker()
+		for j := 0; j < i; j += 1 {
+			s.Reset("a")
+		}
+		s.Focus("a")
+		if err := s.Save(); err != nil {
+			t.Fatalf("save %d: %s", i, err)
+		}
+	}
+
+	history, err := readStats()
+	if err != nil {
+		t.Fatalf("read stats: %s", err)
+	}
+	if len(history.Sessions) != 2 {
+		t.Fatalf("got %d sessions, want 2", len(history.Sessions))
+	}
+	for i, session := range history.Sessions {
+		inst := session.Instances["a"]
+		if inst == nil || inst.Resets != i+1 || inst.Focuses != 1 {
+			t.Errorf("session %d: got %+v", i+1, inst)
+		}
+		if session.End.Before(session.Start) {
+			t.Errorf("session %d: ends before it starts", i+1)
+		}
+	}
+}
+
+func TestPrintStats(t *testing.T) {
+	useDataDirectory(t)
+	var out bytes.Buffer
+	if err := PrintStats(&out); err != nil {
+		t.Fatalf("print empty stats: %s", err)
+	}
+	if got := out.String(); got != "No sessions recorded.\n" {
+		t.Errorf("empty stats: got %q", got)
+	}
+
+	start := time.Date(2023, 6, 1, 20, 0, 0, 0, time.UTC)
+	history := statsHistory{Sessions: []SessionStats{
+		{
+			Start: start,
+			End:   start.Add(time.Hour),
+			Instances: map[string]*InstanceStats{
+				"/b": {Resets: 30, ResToggles: 2, Focuses: 3, FocusTime: 10 * time.Minute},
+				"/a": {Resets: 60, Focuses: 1, FocusTime: time.Minute},
+			},
+		},
+		{
+			Start: start.Add(24 * time.Hour),
+			End:   start.Add(24*time.Hour + 30*time.Minute),
+			Instances: map[string]*InstanceStats{
+				"/a": {Resets: 45, ResToggles: 1, Focuses: 2, FocusTime: 5 * time.Minute},
+			},
+		},
+	}}
+	buf, err := json.Marshal(history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(res.GetDataDirectory()+statsFile, buf, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `Session 1: 2023-06-01 20:00:00
+  Length: 1h0m0s
+  Resets: 90 (90.0/hour)
+  /a: 60 resets, 0 resolution toggles, 1m0s focused
+  /b: 30 resets, 2 resolution toggles, 10m0s focused
+Session 2: 2023-06-02 20:00:00
+  Length: 30m0s
+  Resets: 45 (90.0/hour)
+  /a: 45 resets, 1 resolution toggles, 5m0s focused
+Total:
+  Length: 1h30m0s
+  Resets: 135 (90.0/hour)
+  /a: 105 resets, 1 resolution toggles, 6m0s focused
+  /b: 30 resets, 2 resolution toggles, 10m0s focused
+`
+	out.Reset()
+	if err := PrintStats(&out); err != nil {
+		t.Fatalf("print stats: %s", err)
+	}
+	if got := out.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,182 @@
+package ctl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tesselslate/resetti/internal/cfg"
+	"github.com/tesselslate/resetti/internal/log"
+)
+
+// How often to look for old worlds.
+const worldCleanInterval = 30 * time.Second
+
+// Names of the worlds created by Atum. Other worlds are never touched.
+var resetWorldName = regexp.MustCompile(`^(Random|Set) Speedrun #\d+`)
+
+// worldCleaner moves or deletes old worlds from the instance's saves folder in
+// the background, so that they do not pile up and slow down the filesystem.
+type worldCleaner struct {
+	conf  *cfg.Worlds
+	saves string
+	holds *worldHolds
+}
+
+// worldHolds is the set of worlds which the world cleaner must leave alone,
+// such as worlds which are still being archived.
+type worldHolds struct {
+	mu    sync.Mutex
+	names map[string]int
+}
+
+// savedWorld is a world in the saves folder.
+type savedWorld struct {
+	name    string
+	modTime time.Time
+}
+
+// newWorldCleaner creates a new worldCleaner for the given instance directory.
+func newWorldCleaner(conf *cfg.Worlds, dir string, holds *worldHolds) *worldCleaner {
+	return &worldCleaner{conf, dir + "/saves", holds}
+}
+
+// newWorldHolds creates a new, empty worldHolds.
+func newWorldHolds() *worldHolds {
+	return &worldHolds{names: make(map[string]int)}
+}
+
+// Add holds back the given world from the world cleaner until Remove is
+// called for it.
+func (h *worldHolds) Add(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.names[name] += 1
+}
+
+// Has returns whether the given world is held back.
+func (h *worldHolds) Has(name string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.names[name] > 0
+}
+
+// Remove releases a hold on the given world.
+func (h *worldHolds) Remove(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.names[name] -= 1
+	if h.names[name] <= 0 {
+		delete(h.names, name)
+	}
+}
+
+// Run periodically cleans up old worlds until the context is cancelled.
+func (w *worldCleaner) Run(ctx context.Context) {
+	delay := time.Minute / time.Duration(w.conf.Rate)
+	ticker := time.NewTicker(worldCleanInterval)
+	defer ticker.Stop()
+	for {
+		worlds, err := w.oldWorlds()
+		if err != nil {
+			log.Error("World cleaner: %s", err)
+		}
+		for _, world := range worlds {
+			// The world may have been held back since it was listed.
+			if w.holds.Has(world) {
+				continue
+			}
+			if err := w.remove(world); err != nil {
+				log.Error("World cleaner: %s", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// oldWorlds returns the names of the worlds which should be removed. The
+// newest world is never returned, since it may be in use, and neither are
+// held worlds.
+func (w *worldCleaner) oldWorlds() ([]string, error) {
+	worlds, err := listWorlds(w.saves)
+	if err != nil {
+		return nil, err
+	}
+
+	maxAge := time.Duration(w.conf.MaxAge) * time.Minute
+	var old []string
+	for idx, world := range worlds {
+		if idx == 0 || w.holds.Has(world.name) {
+			continue
+		}
+		tooMany := w.conf.Keep > 0 && idx >= w.conf.Keep
+		tooOld := maxAge > 0 && time.Since(world.modTime) > maxAge
+		if tooMany || tooOld {
+			old = append(old, world.name)
+		}
+	}
+	return old, nil
+}
+
+// listWorlds returns the worlds created by Atum in the given saves folder,
+// from newest to oldest.
+func listWorlds(saves string) ([]savedWorld, error) {
+	entries, err := os.ReadDir(saves)
+	if err != nil {
+		return nil, fmt.Errorf("read saves: %w", err)
+	}
+	var worlds []savedWorld
+	for _, entry := range entries {
+		if !entry.IsDir() || !resetWorldName.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("stat world %q: %w", entry.Name(), err)
+		}
+		worlds = append(worlds, savedWorld{entry.Name(), info.ModTime()})
+	}
+	sort.Slice(worlds, func(i, j int) bool {
+		return worlds[i].modTime.After(worlds[j].modTime)
+	})
+	return worlds, nil
+}
+
+// remove moves or deletes the given world.
+func (w *worldCleaner) remove(name string) error {
+	path := filepath.Join(w.saves, name)
+	if w.conf.MoveTo == "" {
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("delete world %q: %w", name, err)
+		}
+		log.Debug("World cleaner: Deleted %q", name)
+		return nil
+	}
+	dest := filepath.Join(w.conf.MoveTo, name)
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("move world %q: %s already exists", name, dest)
+	}
+	if err := os.Rename(path, dest); err != nil {
+		return fmt.Errorf("move world %q: %w", name, err)
+	}
+	log.Debug("World cleaner: Moved %q", name)
+	return nil
+}
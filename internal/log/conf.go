@@ -11,6 +11,12 @@ import (
 type LogConf struct {
 	LogLevel LogLevel `json:"log_level"`
 	FilePath string   `json:"file_path"`
+	Format   string   `json:"format"`   // "text", "json", or "logfmt"
+	MaxSize  int64    `json:"max_size"` // Size in bytes at which the log file is rotated
+	Backups  int      `json:"backups"`  // Number of rotated log files to keep
+
+	// Log levels of specific modules, which override LogLevel.
+	Modules map[string]LogLevel `json:"modules,omitempty"`
 }
 
 // ConfRead reads the configuration from `/tmp/resetti.json` and returns a LogConf instance.
@@ -30,6 +36,26 @@ func (c *LogConf) UpdateLevel(level LogLevel) error {
 	return c.Write()
 }
 
+// Configure is used to update the format and rotation settings of the
+// configuration in `/tmp/resetti.json`.
+func Configure(format string, maxSize int64, backups int) error {
+	conf, err := ConfRead()
+	if err != nil {
+		return err
+	}
+	conf.Format = format
+	conf.MaxSize = maxSize
+	conf.Backups = backups
+	return conf.Write()
+}
+
+// UpdateModules is used to set the log levels of specific modules in
+// `/tmp/resetti.json`.
+func (c *LogConf) UpdateModules(modules map[string]LogLevel) error {
+	c.Modules = modules
+	return c.Write()
+}
+
 // Write is used to write a configuration to `/tmp/resetti.json`.
 func (c *LogConf) Write() error {
 	logFile, err := os.OpenFile("/tmp/resetti.json", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
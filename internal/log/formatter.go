@@ -1,7 +1,9 @@
 package log
 
 import (
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -58,3 +60,26 @@ func Format(level string, message string, formatStr string) (string, error) {
 	}
 	return formatStr + "\n", nil
 }
+
+// FormatJson formats a log message as a single line of JSON.
+func FormatJson(level string, message string) (string, error) {
+	line, err := json.Marshal(struct {
+		Time    string `json:"time"`
+		Level   string `json:"level"`
+		Message string `json:"msg"`
+	}{time.Now().Format(time.RFC3339), strings.ToLower(level), message})
+	if err != nil {
+		return "", fmt.Errorf("Failed FormatJson: %s", err)
+	}
+	return string(line) + "\n", nil
+}
+
+// FormatLogfmt formats a log message as a single logfmt line.
+func FormatLogfmt(level string, message string) string {
+	return fmt.Sprintf(
+		"time=%s level=%s msg=%s\n",
+		time.Now().Format(time.RFC3339),
+		strings.ToLower(level),
+		strconv.Quote(message),
+	)
+}
@@ -4,10 +4,26 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"runtime"
+	"strings"
 )
 
 type LogLevel int
 
+// Names of each log level, as used in configuration profiles.
+var levelNames = map[string]LogLevel{
+	"error":   ERROR,
+	"warn":    WARN,
+	"info":    INFO,
+	"debug":   DEBUG,
+	"verbose": VERBOSE,
+}
+
+// Modules contains the names of the packages which can be given their own log
+// level. The package-level logging functions find the module of a message
+// from the package of their caller.
+var Modules = []string{"main", "cfg", "ctl", "mc"}
+
 // The level of visibility of the log output.
 // ERROR is the lowest level, VERBOSE is the highest and it increases in the order that it is written.
 const (
@@ -18,6 +34,11 @@ const (
 	VERBOSE
 )
 
+// detached is set by Detach. When it is set, the package-level logging
+// functions print to stderr instead of rebuilding the logger from the conf
+// file.
+var detached bool
+
 // Logger is exposed to the user and all logging is done through it.
 // It handles its internal errors, so the user doesn't have to catch any.
 // It maintains LogLevel data, a Formatter instance and a Writer instance.
@@ -26,8 +47,13 @@ const (
 type Logger struct {
 	conf      LogConf
 	level     LogLevel
+	format    string
 	formatStr string
+	maxSize   int64
+	backups   int
+	modules   map[string]LogLevel
 	logFile   *os.File
+	console   io.Writer
 	logWriter io.Writer
 }
 
@@ -49,16 +75,9 @@ func NewLogger(level LogLevel, filePath string, disableConsole bool) Logger {
 		fmt.Printf("Couldn't create log file: %s\n", err)
 		os.Exit(1)
 	}
-	var logWriter io.Writer
+	var console io.Writer = os.Stdout
 	if disableConsole {
-		nullFile, err := os.OpenFile(os.DevNull, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
-		if err != nil {
-			fmt.Printf("Couldn't open null file: %s\n", err)
-			os.Exit(1)
-		}
-		logWriter = io.MultiWriter(logFile, nullFile)
-	} else {
-		logWriter = io.MultiWriter(logFile, os.Stdout)
+		console = io.Discard
 	}
 	conf := LogConf{LogLevel: level, FilePath: filePath}
 	err = conf.Write()
@@ -66,12 +85,32 @@ func NewLogger(level LogLevel, filePath string, disableConsole bool) Logger {
 		fmt.Printf("Couldn't create conf file: %s\n", err)
 		os.Exit(1)
 	}
-	return Logger{conf: conf, level: level, formatStr: "{ascTime}: [{level}] - {message}", logFile: logFile, logWriter: logWriter}
+	return Logger{conf: conf, level: level, formatStr: "{ascTime}: [{level}] - {message}", logFile: logFile, console: console, logWriter: io.MultiWriter(logFile, console)}
+}
+
+// Detach makes Rebuild (and so the package-level logging functions) return a
+// logger which only prints to stderr. It never reads or writes the conf file
+// or log file, so it can be used by short-lived subcommands without
+// disturbing the log of a running resetti session.
+func Detach() {
+	detached = true
+}
+
+// ParseLevel returns the log level with the given name (e.g. "debug".)
+func ParseLevel(name string) (LogLevel, error) {
+	level, ok := levelNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown log level %q", name)
+	}
+	return level, nil
 }
 
 // Rebuild loads an existing Logger instance from disk.
 // It parses the conf file in `/tmp/resetti.json` and builds a new Logger instance.
 func Rebuild() Logger {
+	if detached {
+		return Logger{level: INFO, formatStr: "{ascTime}: [{level}] - {message}", console: os.Stderr, logWriter: os.Stderr}
+	}
 	conf, err := ConfRead()
 	if err != nil {
 		fmt.Printf("Conf error: %s", err)
@@ -83,7 +122,34 @@ func Rebuild() Logger {
 		os.Exit(1)
 	}
 	logWriter := io.MultiWriter(logFile, os.Stdout)
-	return Logger{level: conf.LogLevel, formatStr: "{ascTime}: [{level}] - {message}", logFile: logFile, logWriter: logWriter}
+	return Logger{level: conf.LogLevel, format: conf.Format, formatStr: "{ascTime}: [{level}] - {message}", maxSize: conf.MaxSize, backups: conf.Backups, modules: conf.Modules, logFile: logFile, console: os.Stdout, logWriter: logWriter}
+}
+
+// rebuildFor rebuilds the logger for a message from the calling function's
+// caller, using the log level of its module if one is set.
+func rebuildFor() Logger {
+	logger := Rebuild()
+	if level, ok := logger.modules[callerModule(3)]; ok {
+		logger.level = level
+	}
+	return logger
+}
+
+// callerModule returns the name of the package containing the function skip
+// frames above it (e.g. "ctl" for github.com/tesselslate/resetti/internal/ctl.)
+func callerModule(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip)
+	if !ok {
+		return ""
+	}
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return ""
+	}
+	name := fn.Name()
+	name = name[strings.LastIndexByte(name, '/')+1:]
+	module, _, _ := strings.Cut(name, ".")
+	return module
 }
 
 // SetLevel sets the log visibility level of the Logger instance.
@@ -99,23 +165,38 @@ func (l *Logger) SetLevel(level LogLevel) {
 // SetConsole sets the output type for the Logger instance.
 func (l *Logger) SetConsole(disableConsole bool) {
 	if disableConsole {
-		nullFile, err := os.OpenFile(os.DevNull, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
-		if err != nil {
-			fmt.Printf("Log update error: %s\n", err)
-			os.Exit(1)
-		}
-		l.logWriter = io.MultiWriter(l.logFile, nullFile)
+		l.console = io.Discard
 	} else {
-		l.logWriter = io.MultiWriter(l.logFile, os.Stdout)
+		l.console = os.Stdout
 	}
+	l.logWriter = io.MultiWriter(l.logFile, l.console)
 }
 
 // Write formats the message and flushes it to the Sinks using io.Writer
 func (l *Logger) Write(level string, message string) error {
-	formattedStr, err := Format(level, message, l.formatStr)
+	var formattedStr string
+	var err error
+	switch l.format {
+	case "json":
+		formattedStr, err = FormatJson(level, message)
+	case "logfmt":
+		formattedStr = FormatLogfmt(level, message)
+	default:
+		formattedStr, err = Format(level, message, l.formatStr)
+	}
 	if err != nil {
 		return fmt.Errorf("Format failed: %s", err)
 	}
+	if l.logFile != nil {
+		if err := l.reopen(); err != nil {
+			return fmt.Errorf("Failed to reopen logs: %s", err)
+		}
+	}
+	if l.maxSize > 0 {
+		if err := l.rotate(); err != nil {
+			return fmt.Errorf("Failed to rotate logs: %s", err)
+		}
+	}
 	byteStr := []byte(formattedStr)
 	_, err = l.logWriter.Write(byteStr)
 	if err != nil {
@@ -124,6 +205,67 @@ func (l *Logger) Write(level string, message string) error {
 	return nil
 }
 
+// reopen reopens the log file if it was moved away (e.g. rotated by another
+// logger) since this logger opened it.
+func (l *Logger) reopen() error {
+	info, err := l.logFile.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	current, err := os.Stat(l.logFile.Name())
+	if err == nil && os.SameFile(info, current) {
+		return nil
+	}
+	return l.openFile()
+}
+
+// rotate moves the log file to a backup and starts a new log file if it has
+// grown past the maximum size. Older backups are shifted along (resetti.log.1
+// becomes resetti.log.2, and so on) and the oldest one is removed.
+func (l *Logger) rotate() error {
+	info, err := l.logFile.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() || info.Size() < l.maxSize {
+		return nil
+	}
+	path := l.logFile.Name()
+	backups := l.backups
+	if backups < 1 {
+		backups = 1
+	}
+	for i := backups - 1; i > 0; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(path, path+".1"); err != nil {
+		return err
+	}
+	return l.openFile()
+}
+
+// openFile closes the current log file and opens the log file's path again,
+// creating it if needed.
+func (l *Logger) openFile() error {
+	path := l.logFile.Name()
+	if err := l.logFile.Close(); err != nil {
+		return err
+	}
+	var err error
+	l.logFile, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	l.logWriter = io.MultiWriter(l.logFile, l.console)
+	return nil
+}
+
 // Error prints out the error message passed to the Sinks.
 func (l *Logger) Error(message string, args ...any) {
 	err := l.Write("ERROR", fmt.Sprintf(message, args...))
@@ -187,31 +329,31 @@ func (l *Logger) Verbose(message string, args ...any) {
 
 // Error is a wrapper function that re-creates the logger instance from config and writes to it.
 func Error(message string, args ...any) {
-	logger := Rebuild()
+	logger := rebuildFor()
 	logger.Error(message, args...)
 }
 
 // Warn is a wrapper function that re-creates the logger instance from config and writes to it.
 func Warn(message string, args ...any) {
-	logger := Rebuild()
+	logger := rebuildFor()
 	logger.Warn(message, args...)
 }
 
 // Info is a wrapper function that re-creates the logger instance from config and writes to it.
 func Info(message string, args ...any) {
-	logger := Rebuild()
+	logger := rebuildFor()
 	logger.Info(message, args...)
 }
 
 // Debug is a wrapper function that re-creates the logger instance from config and writes to it.
 func Debug(message string, args ...any) {
-	logger := Rebuild()
+	logger := rebuildFor()
 	logger.Debug(message, args...)
 }
 
 // Verbose is a wrapper function that re-creates the logger instance from config and writes to it.
 func Verbose(message string, args ...any) {
-	logger := Rebuild()
+	logger := rebuildFor()
 	logger.Verbose(message, args...)
 }
 
@@ -0,0 +1,86 @@
+package mc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInstanceCrashed is wrapped by the CrashError sent by Manager.Run when the
+// managed instance writes a crash report.
+var ErrInstanceCrashed = errors.New("instance crashed")
+
+// CrashError is sent by Manager.Run when the managed instance writes a crash
+// report.
+type CrashError struct {
+	Dir    string // .minecraft directory of the instance
+	Report string // Path to the crash report
+}
+
+// crashWatcher looks for new crash reports written by an instance, either by
+// Minecraft (in crash-reports) or by the JVM (hs_err_pid*.log.)
+type crashWatcher struct {
+	dir  string
+	seen map[string]bool
+}
+
+// newCrashWatcher creates a new crashWatcher for the given instance directory.
+// Any crash reports which already exist are ignored.
+func newCrashWatcher(dir string) (crashWatcher, error) {
+	w := crashWatcher{dir, make(map[string]bool)}
+	reports, err := w.reports()
+	if err != nil {
+		return w, err
+	}
+	for _, report := range reports {
+		w.seen[report] = true
+	}
+	return w, nil
+}
+
+// Check returns the paths of any crash reports written since the last check.
+func (w *crashWatcher) Check() ([]string, error) {
+	reports, err := w.reports()
+	if err != nil {
+		return nil, err
+	}
+	var found []string
+	for _, report := range reports {
+		if !w.seen[report] {
+			w.seen[report] = true
+			found = append(found, report)
+		}
+	}
+	return found, nil
+}
+
+// reports returns the paths of all crash reports in the instance directory.
+func (w *crashWatcher) reports() ([]string, error) {
+	var reports []string
+	entries, err := os.ReadDir(w.dir + "/crash-reports")
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read crash-reports: %w", err)
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".txt") {
+			reports = append(reports, w.dir+"/crash-reports/"+entry.Name())
+		}
+	}
+	jvmReports, err := filepath.Glob(w.dir + "/hs_err_pid*.log")
+	if err != nil {
+		return nil, err
+	}
+	return append(reports, jvmReports...), nil
+}
+
+// Error implements error.
+func (e *CrashError) Error() string {
+	return fmt.Sprintf("%s: %s (%s)", e.Dir, ErrInstanceCrashed, e.Report)
+}
+
+// Unwrap returns ErrInstanceCrashed.
+func (e *CrashError) Unwrap() error {
+	return ErrInstanceCrashed
+}
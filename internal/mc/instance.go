@@ -2,12 +2,13 @@ package mc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"sync"
+	"syscall"
 	"time"
 
-	"github.com/jezek/xgb/xproto"
 	"github.com/tesselslate/resetti/internal/cfg"
 	"github.com/tesselslate/resetti/internal/log"
 	"github.com/tesselslate/resetti/internal/x11"
@@ -15,11 +16,15 @@ import (
 
 // TODO: Pre 1.14 support
 
+// ErrInstanceDied is returned by Manager.Run when the managed instance's
+// process exits.
+var ErrInstanceDied = errors.New("instance died")
+
 // An instance contains all of the relevant information for an instance, such
 // as its game directory and current state.
 type instance struct {
-	info   InstanceInfo
-	altRes bool
+	info InstanceInfo
+	res  int // Alternate resolution in use (-1 for the normal resolution.)
 }
 
 // A Manager controls several Minecraft instances. It keeps track of each
@@ -39,7 +44,7 @@ type Manager struct {
 // NewManager attempts to create a new Manager for the given instances.
 func NewManager(info InstanceInfo, conf *cfg.Profile, x *x11.Client) (*Manager, error) {
 	// Create instance.
-	instance := instance{info, false}
+	instance := instance{info, -1}
 
 	m := Manager{
 		sync.Mutex{},
@@ -53,24 +58,88 @@ func NewManager(info InstanceInfo, conf *cfg.Profile, x *x11.Client) (*Manager,
 }
 
 // Run starts managing instances in the background. Any non-fatal errors are
-// logged, any fatal errors are returned via the provided error channel.
-func (m *Manager) Run(ctx context.Context) {
+// logged. Fatal errors and problems the user should know about (such as a
+// stuck instance) are sent via the provided error channel.
+func (m *Manager) Run(ctx context.Context, errch chan<- error) {
 	instanceCheckup := time.NewTicker(time.Second)
+	defer instanceCheckup.Stop()
+	timeout := time.Duration(m.conf.Watchdog.Timeout) * time.Second
+	watchdog := newWatchdog(timeout)
+	crashes, err := newCrashWatcher(m.instance.info.Dir)
+	if err != nil {
+		log.Warn("Crash watcher: %s", err)
+	}
+	send := func(err error) {
+		select {
+		case errch <- err:
+		case <-ctx.Done():
+		}
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-instanceCheckup.C:
+			m.mu.Lock()
 			inst := m.instance
-			_, err := os.Stat(fmt.Sprintf("/proc/%d/", inst.info.Pid))
+			m.mu.Unlock()
+			// Check for crash reports before checking if the instance died,
+			// since the crash report is written before the game exits.
+			reports, err := crashes.Check()
+			if err != nil {
+				log.Warn("Crash watcher: %s", err)
+			}
+			for _, report := range reports {
+				log.Error("Instance (%s) crashed: %s", inst.info.Dir, report)
+				send(&CrashError{inst.info.Dir, report})
+			}
+			_, err = os.Stat(fmt.Sprintf("/proc/%d/", inst.info.Pid))
 			if err != nil {
 				log.Warn("Instance (%s) died. Reboot it and restart resetti.", inst.info.Dir)
+				send(fmt.Errorf("%s: %w", inst.info.Dir, ErrInstanceDied))
+				return
+			}
+			if timeout == 0 {
+				continue
+			}
+			reason, err := watchdog.Check(inst.info)
+			if err != nil {
+				log.Warn("Watchdog: %s", err)
+				continue
+			}
+			if reason != "" {
+				log.Warn("Instance (%s) is stuck: %s", inst.info.Dir, reason)
+				send(fmt.Errorf("%s: %s: %w", inst.info.Dir, reason, ErrInstanceStuck))
 			}
 		}
 	}
 }
 
+// AltRes returns whether or not the instance is using an alternate
+// resolution.
+func (m *Manager) AltRes() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.instance.res != -1
+}
+
+// BypassCompositor asks the compositor to stop (or resume) compositing the
+// instance's window. Any errors will be logged.
+func (m *Manager) BypassCompositor(bypass bool) {
+	if err := m.x.SetBypassCompositor(m.instance.info.Wid, bypass); err != nil {
+		log.Error("BypassCompositor failed: %s", err)
+	}
+}
+
+// Continue sends SIGCONT to the instance, in case its process was stopped. Any
+// errors will be logged.
+func (m *Manager) Continue() {
+	if err := syscall.Kill(int(m.instance.info.Pid), syscall.SIGCONT); err != nil {
+		log.Error("Continue failed: %s", err)
+	}
+}
+
 // Focus attempts to focus the window of the given instance. Any errors will
 // be logged.
 func (m *Manager) Focus() {
@@ -80,17 +149,21 @@ func (m *Manager) Focus() {
 }
 
 // ToggleResolution switches the given instance between the normal (play)
-// resolution and the given alternate resolution. It returns whether or not
-// the instance is now using the alternate resolution.
+// resolution and the given alternate resolution. If the instance is using a
+// different alternate resolution, it switches directly to the given one. It
+// returns whether or not the instance is now using the alternate resolution.
 func (m *Manager) ToggleResolution(resId int) bool {
-	if m.instance.altRes {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.instance.res == resId {
 		m.setResolution(m.conf.NormalRes)
+		m.instance.res = -1
 	} else {
 		m.setResolution(&m.conf.AltRes[resId])
+		m.instance.res = resId
 	}
-	m.instance.altRes = !m.instance.altRes
 	m.Focus()
-	return m.instance.altRes
+	return m.instance.res != -1
 }
 
 // Reset attempts to reset the given instance. The return value will indicate
@@ -101,27 +174,20 @@ func (m *Manager) Reset() bool {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Ghost pie fix.
-	m.sendKeyUp(x11.KeyShift)
-	m.sendKeyPress(x11.KeyF3)
-	if m.instance.altRes {
+	// Ghost pie fix, then reset.
+	batch := x11.NewKeyBatch(m.instance.info.Wid)
+	batch.KeyUp(x11.KeyShift)
+	batch.KeyPress(x11.KeyF3)
+	batch.KeyPress(m.instance.info.ResetKey)
+	m.x.SendKeyBatch(batch)
+
+	if m.instance.res != -1 {
 		m.setResolution(m.conf.NormalRes)
-		m.instance.altRes = false
+		m.instance.res = -1
 	}
-	m.sendKeyPress(m.instance.info.ResetKey)
 	return true
 }
 
-// sendKeyPress sends a key down and key up event to the given instance.
-func (m *Manager) sendKeyPress(key xproto.Keycode) {
-	m.x.SendKeyPress(key, m.instance.info.Wid)
-}
-
-// sendKeyUp sends a key up event to the given instance.
-func (m *Manager) sendKeyUp(key xproto.Keycode) {
-	m.x.SendKeyUp(key, m.instance.info.Wid)
-}
-
 // setResolution sets the window geometry of an instance.
 func (m *Manager) setResolution(rect *cfg.Rectangle) {
 	if rect == nil {
@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"github.com/jezek/xgb/xproto"
+	"github.com/tesselslate/resetti/internal/cfg"
 	"github.com/tesselslate/resetti/internal/x11"
 )
 
@@ -33,20 +34,45 @@ type InstanceInfo struct {
 	ResetKey xproto.Keycode // Atum reset key
 }
 
-// FindInstance returns the running Minecraft instance,
-// or an error if it doesn't find any.
-func FindInstance(x *x11.Client) (InstanceInfo, error) {
+// FindInstance returns the running Minecraft instance which matches the
+// given settings, or an error if it doesn't find any.
+func FindInstance(x *x11.Client, conf *cfg.Instance) (InstanceInfo, error) {
 	windows := x.GetWindowList()
+	if conf.Window != 0 {
+		windows = []xproto.Window{xproto.Window(conf.Window)}
+	}
+	var pid uint32
+	if conf.PidFile != "" {
+		buf, err := os.ReadFile(conf.PidFile)
+		if err != nil {
+			return InstanceInfo{}, fmt.Errorf("read pid file: %w", err)
+		}
+		rawPid, err := strconv.ParseUint(strings.TrimSpace(string(buf)), 10, 32)
+		if err != nil {
+			return InstanceInfo{}, fmt.Errorf("parse pid file: %w", err)
+		}
+		pid = uint32(rawPid)
+	}
+
+	// If the user named the instance's window or process, the default class
+	// check is skipped, since the launcher may have renamed the window.
+	explicit := conf.Window != 0 || conf.PidFile != ""
 
 	// Check every window to see if it is a Minecraft instance.
 	for _, win := range windows {
 		// Skip this window if it is not a Minecraft instance.
-		if !isMinecraftWindow(x, win) {
+		if !isMinecraftWindow(x, win, conf, explicit) {
 			continue
 		}
+		if pid != 0 {
+			winPid, err := x.GetWindowPid(win)
+			if err != nil || winPid != pid {
+				continue
+			}
+		}
 
 		// Get the info for this instance.
-		info, was_instance, err := getInstanceInfo(x, win)
+		info, was_instance, err := getInstanceInfo(x, win, conf, explicit)
 		if was_instance {
 			if err != nil {
 				return InstanceInfo{}, fmt.Errorf("unusable instance: %w", err)
@@ -58,8 +84,10 @@ func FindInstance(x *x11.Client) (InstanceInfo, error) {
 }
 
 // getInstanceInfo attempts to gather information about the given Minecraft
-// instance.
-func getInstanceInfo(x *x11.Client, win xproto.Window) (InstanceInfo, bool, error) {
+// instance. If the instance was explicitly selected by the user, a window
+// title without a version is an error rather than a sign that the window is
+// not an instance.
+func getInstanceInfo(x *x11.Client, win xproto.Window, conf *cfg.Instance, explicit bool) (InstanceInfo, bool, error) {
 	// Get process ID.
 	pid, err := x.GetWindowPid(win)
 	if err != nil {
@@ -74,17 +102,19 @@ func getInstanceInfo(x *x11.Client, win xproto.Window) (InstanceInfo, bool, erro
 	pwd := string(rawPwd)
 
 	// Get game version.
-	title, err := x.GetWindowTitle(win)
-	if err != nil {
-		return InstanceInfo{}, false, err
-	}
-	versionString := strings.Split(
-		strings.Split(title, " ")[1],
-		".",
-	)[1]
-	version, err := strconv.Atoi(versionString)
-	if err != nil {
-		return InstanceInfo{}, false, err
+	version := conf.Version
+	if version == 0 {
+		title, err := x.GetWindowTitle(win)
+		if err != nil {
+			return InstanceInfo{}, false, err
+		}
+		version, err = parseTitleVersion(title)
+		if err != nil {
+			if explicit {
+				err = fmt.Errorf("%w (set instance.version)", err)
+			}
+			return InstanceInfo{}, explicit, err
+		}
 	}
 	if version < 14 {
 		return InstanceInfo{}, false, errors.New("only 1.14 and newer are currently supported")
@@ -136,6 +166,24 @@ func getInstanceInfo(x *x11.Client, win xproto.Window) (InstanceInfo, bool, erro
 	}, true, nil
 }
 
+// parseTitleVersion returns the minor version of the game from the given window
+// title (e.g. 16 for "Minecraft* 1.16.1".)
+func parseTitleVersion(title string) (int, error) {
+	titleParts := strings.Split(title, " ")
+	if len(titleParts) < 2 {
+		return 0, fmt.Errorf("no version in window title %q", title)
+	}
+	versionParts := strings.Split(titleParts[1], ".")
+	if len(versionParts) < 2 {
+		return 0, fmt.Errorf("no version in window title %q", title)
+	}
+	version, err := strconv.Atoi(versionParts[1])
+	if err != nil {
+		return 0, fmt.Errorf("no version in window title %q", title)
+	}
+	return version, nil
+}
+
 // hasModernWp determines whether or not the instance has a WorldPreview build
 // with wpstateout.txt.
 func hasModernWp(dir string) (bool, error) {
@@ -175,19 +223,33 @@ func hasModernWp(dir string) (bool, error) {
 
 // isMinecraftWindow determines whether or not the window is a Minecraft
 // window.
-func isMinecraftWindow(x *x11.Client, win xproto.Window) bool {
-	// Check that the window has "Minecraft" in its class.
+func isMinecraftWindow(x *x11.Client, win xproto.Window, conf *cfg.Instance, explicit bool) bool {
+	// Check that the window's class (and title, if configured) match. By
+	// default, the class must contain "Minecraft", unless the user selected
+	// the window or process explicitly.
 	//
 	// There are more checks which could be performed here (e.g. checking that
 	// the executable is java, and that the process working directory is a
 	// valid Minecraft directory), but any false positives are weeded out when
 	// getting instance info.
-	class, err := x.GetWindowClass(win)
-	if err != nil {
-		return false
+	if conf.Class.Regexp != nil || !explicit {
+		class, err := x.GetWindowClass(win)
+		if err != nil {
+			return false
+		}
+		if conf.Class.Regexp != nil {
+			if !conf.Class.MatchString(class) {
+				return false
+			}
+		} else if !strings.Contains(class, "Minecraft") {
+			return false
+		}
 	}
-	if !strings.Contains(class, "Minecraft") {
-		return false
+	if conf.Title.Regexp != nil {
+		title, err := x.GetWindowTitle(win)
+		if err != nil || !conf.Title.MatchString(title) {
+			return false
+		}
 	}
 	return true
 }
@@ -0,0 +1,30 @@
+package mc
+
+import "testing"
+
+func TestParseTitleVersion(t *testing.T) {
+	tests := []struct {
+		title string
+		want  int
+		ok    bool
+	}{
+		{"Minecraft* 1.16.1", 16, true},
+		{"Minecraft 1.16.1 - Singleplayer", 16, true},
+		{"Minecraft* 1.14", 14, true},
+		{"Minecraft* 1.19.4 - Multiplayer (3rd-party Server)", 19, true},
+		{"Minecraft*", 0, false},
+		{"Minecraft* Launcher", 0, false},
+		{"Minecraft* 1.x", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseTitleVersion(tt.title)
+		if tt.ok && err != nil {
+			t.Errorf("parseTitleVersion(%q) got error %q", tt.title, err)
+		} else if !tt.ok && err == nil {
+			t.Errorf("parseTitleVersion(%q) got no error", tt.title)
+		} else if got != tt.want {
+			t.Errorf("parseTitleVersion(%q) = %d, want %d", tt.title, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package mc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrInstanceStuck is sent by Manager.Run when the watchdog finds that the
+// managed instance has stopped making progress. The receiver is responsible
+// for performing the configured watchdog action.
+var ErrInstanceStuck = errors.New("instance stuck")
+
+// A watchdog detects when an instance gets stuck, either while generating a
+// world (if it has wpstateout.txt) or because its process was stopped and
+// never continued (e.g. by a crashed script.)
+type watchdog struct {
+	timeout time.Duration
+
+	state      string    // Last state read from wpstateout.txt
+	stateStart time.Time // When the state last changed
+	stoppedAt  time.Time // When the process was first seen stopped (zero if running)
+
+	missingState bool // Whether wpstateout.txt was missing at the last check
+}
+
+// newWatchdog creates a new watchdog which considers an instance stuck after
+// the given timeout.
+func newWatchdog(timeout time.Duration) watchdog {
+	now := time.Now()
+	return watchdog{timeout: timeout, stateStart: now}
+}
+
+// Check checks whether or not the instance is stuck. If it is, a description
+// of why is returned and the watchdog waits for another full timeout before
+// reporting the instance again.
+func (w *watchdog) Check(info InstanceInfo) (string, error) {
+	now := time.Now()
+	stopped, err := isProcessStopped(info.Pid)
+	if err != nil {
+		return "", fmt.Errorf("check process state: %w", err)
+	}
+	if !stopped {
+		w.stoppedAt = time.Time{}
+	} else if w.stoppedAt.IsZero() {
+		w.stoppedAt = now
+	} else if now.Sub(w.stoppedAt) >= w.timeout {
+		w.stoppedAt = now
+		return "process stopped", nil
+	}
+
+	if !info.ModernWp {
+		return "", nil
+	}
+	buf, err := os.ReadFile(info.Dir + "/wpstateout.txt")
+	if err != nil {
+		// Only report a missing state file once, rather than every second
+		// until the game writes it.
+		if errors.Is(err, os.ErrNotExist) {
+			if w.missingState {
+				return "", nil
+			}
+			w.missingState = true
+		}
+		return "", fmt.Errorf("read wpstateout: %w", err)
+	}
+	w.missingState = false
+	state := strings.TrimSpace(string(buf))
+	if state != w.state {
+		w.state = state
+		w.stateStart = now
+		return "", nil
+	}
+	generating := strings.HasPrefix(state, "generating") || state == "waiting"
+	if generating && now.Sub(w.stateStart) >= w.timeout {
+		w.stateStart = now
+		return fmt.Sprintf("no progress while %q", state), nil
+	}
+	return "", nil
+}
+
+// isProcessStopped returns whether or not the given process is stopped (e.g.
+// by SIGSTOP.)
+func isProcessStopped(pid uint32) (bool, error) {
+	buf, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false, err
+	}
+	// The process name is in parentheses and may contain spaces, so the state
+	// is read from after the last closing parenthesis.
+	idx := strings.LastIndexByte(string(buf), ')')
+	if idx == -1 {
+		return false, errors.New("malformed stat")
+	}
+	fields := strings.Fields(string(buf[idx+1:]))
+	if len(fields) == 0 {
+		return false, errors.New("malformed stat")
+	}
+	return fields[0] == "T" || fields[0] == "t", nil
+}
@@ -20,6 +20,11 @@ const (
 //go:embed default.toml
 var DefaultConfig []byte
 
+// Dashboard contains the web page served by the remote control server.
+//
+//go:embed dashboard.html
+var Dashboard []byte
+
 // dataDir contains the directory in which resources are stored. It is assigned
 // by WriteResources on startup.
 var dataDir string
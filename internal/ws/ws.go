@@ -0,0 +1,267 @@
+// Package ws implements a minimal server-side WebSocket (RFC 6455) connection
+// which is sufficient for exchanging small JSON messages with local clients.
+package ws
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Opcodes
+const (
+	opContinuation byte = 0x0
+	opText         byte = 0x1
+	opBinary       byte = 0x2
+	opClose        byte = 0x8
+	opPing         byte = 0x9
+	opPong         byte = 0xA
+)
+
+// The GUID appended to the client's key during the opening handshake.
+const handshakeGuid = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// The largest message which will be accepted from a client.
+const maxMessageSize = 1 << 16
+
+// The largest payload allowed in a control frame.
+const maxControlSize = 125
+
+// How long to wait for the close frame to be written when closing.
+const closeTimeout = time.Second
+
+// Error types
+var (
+	ErrClosed       = errors.New("connection closed")
+	errBadControl   = errors.New("invalid control frame")
+	errBadFragment  = errors.New("unexpected message fragment")
+	errBadOrigin    = errors.New("origin does not match host")
+	errNotWebsocket = errors.New("not a websocket handshake")
+	errTooLarge     = errors.New("message too large")
+	errUnmasked     = errors.New("client frame was not masked")
+)
+
+// Conn is a server-side WebSocket connection.
+type Conn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+
+	// The mutex guards writes to the connection.
+	mu sync.Mutex
+}
+
+// Upgrade performs the opening handshake for the given HTTP request and takes
+// over the underlying connection. Requests from browsers are only accepted if
+// they come from a page served by the same host, so that other websites
+// cannot connect on the user's behalf.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if !sameOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return nil, errBadOrigin
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "expected websocket handshake", http.StatusBadRequest)
+		return nil, errNotWebsocket
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errNotWebsocket
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "cannot upgrade connection", http.StatusInternalServerError)
+		return nil, errors.New("response writer cannot be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("hijack: %w", err)
+	}
+
+	hash := sha1.Sum([]byte(key + handshakeGuid))
+	accept := base64.StdEncoding.EncodeToString(hash[:])
+	_, err = fmt.Fprintf(
+		rw,
+		"HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		accept,
+	)
+	if err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("write handshake: %w", err)
+	}
+	return &Conn{conn: conn, rw: rw}, nil
+}
+
+// Close closes the connection. If a write is blocked (e.g. because the client
+// stopped reading), it is abandoned after a short timeout.
+func (c *Conn) Close() error {
+	_ = c.conn.SetWriteDeadline(time.Now().Add(closeTimeout))
+	_ = c.writeFrame(opClose, nil)
+	return c.conn.Close()
+}
+
+// SetWriteDeadline sets the deadline for future writes to the connection, and
+// any write which is currently blocked. A zero value means writes will not
+// time out.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// ReadMessage reads the next text or binary message from the client. Control
+// frames are handled transparently.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	var message []byte
+	fragmented := false
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		if op >= opClose && (!fin || len(payload) > maxControlSize) {
+			return nil, errBadControl
+		}
+		switch op {
+		case opClose:
+			_ = c.Close()
+			return nil, ErrClosed
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opText, opBinary, opContinuation:
+			// A message starts with a text or binary frame, which may be
+			// followed by continuation frames until one has the fin bit set.
+			if (op == opContinuation) != fragmented {
+				return nil, errBadFragment
+			}
+			message = append(message, payload...)
+			if len(message) > maxMessageSize {
+				return nil, errTooLarge
+			}
+			if fin {
+				return message, nil
+			}
+			fragmented = true
+		default:
+			return nil, fmt.Errorf("unknown opcode %d", op)
+		}
+	}
+}
+
+// WriteMessage writes a single text message to the client.
+func (c *Conn) WriteMessage(data []byte) error {
+	return c.writeFrame(opText, data)
+}
+
+// readFrame reads a single frame from the client.
+func (c *Conn) readFrame() (bool, byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.rw, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin := header[0]&0x80 != 0
+	op := header[0] & 0x0F
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, errUnmasked
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > maxMessageSize {
+		return false, 0, nil, errTooLarge
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// writeFrame writes a single unfragmented frame to the client.
+func (c *Conn) writeFrame(op byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	header := []byte{0x80 | op}
+	switch length := len(payload); {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+// headerContains determines whether the given comma-separated header contains
+// the given token (case insensitive.)
+func headerContains(header http.Header, name string, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameOrigin determines whether the request either has no Origin header (it
+// did not come from a browser) or comes from a page on the requested host.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
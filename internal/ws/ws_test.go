@@ -0,0 +1,189 @@
+package ws
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+)
+
+// frame builds a client frame with the given header fields and payload.
+func frame(fin bool, op byte, payload []byte, masked bool) []byte {
+	first := op
+	if fin {
+		first |= 0x80
+	}
+	buf := []byte{first}
+	var maskBit byte
+	if masked {
+		maskBit = 0x80
+	}
+	switch length := len(payload); {
+	case length < 126:
+		buf = append(buf, maskBit|byte(length))
+	case length <= 0xFFFF:
+		buf = append(buf, maskBit|126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(length))
+	default:
+		buf = append(buf, maskBit|127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(length))
+	}
+	if !masked {
+		return append(buf, payload...)
+	}
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	buf = append(buf, mask[:]...)
+	for i, b := range payload {
+		buf = append(buf, b^mask[i%4])
+	}
+	return buf
+}
+
+// readFrom returns the result of reading one message from a connection which
+// receives the given data from the client.
+func readFrom(data []byte) ([]byte, error) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go func() {
+		_, _ = client.Write(data)
+	}()
+	// Discard anything the server sends back (pongs, close frames.)
+	go func() {
+		_, _ = io.Copy(io.Discard, client)
+	}()
+	conn := &Conn{
+		conn: server,
+		rw:   bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)),
+	}
+	defer server.Close()
+	return conn.ReadMessage()
+}
+
+func TestReadMessage(t *testing.T) {
+	large := bytes.Repeat([]byte("a"), 300)
+	tests := []struct {
+		name   string
+		frames [][]byte
+		want   string
+		err    error
+	}{
+		{
+			name:   "single frame",
+			frames: [][]byte{frame(true, opText, []byte("hello"), true)},
+			want:   "hello",
+		},
+		{
+			name:   "extended length",
+			frames: [][]byte{frame(true, opBinary, large, true)},
+			want:   string(large),
+		},
+		{
+			name: "fragmented",
+			frames: [][]byte{
+				frame(false, opText, []byte("hello "), true),
+				frame(false, opContinuation, []byte("there "), true),
+				frame(true, opContinuation, []byte("world"), true),
+			},
+			want: "hello there world",
+		},
+		{
+			name: "ping between fragments",
+			frames: [][]byte{
+				frame(false, opText, []byte("hello "), true),
+				frame(true, opPing, []byte("ping"), true),
+				frame(true, opContinuation, []byte("world"), true),
+			},
+			want: "hello world",
+		},
+		{
+			name:   "pong",
+			frames: [][]byte{frame(true, opPong, nil, true), frame(true, opText, []byte("x"), true)},
+			want:   "x",
+		},
+		{
+			name:   "continuation without start",
+			frames: [][]byte{frame(true, opContinuation, []byte("world"), true)},
+			err:    errBadFragment,
+		},
+		{
+			name: "new message while fragmented",
+			frames: [][]byte{
+				frame(false, opText, []byte("hello"), true),
+				frame(true, opText, []byte("world"), true),
+			},
+			err: errBadFragment,
+		},
+		{
+			name:   "unmasked",
+			frames: [][]byte{frame(true, opText, []byte("hello"), false)},
+			err:    errUnmasked,
+		},
+		{
+			name:   "fragmented control frame",
+			frames: [][]byte{frame(false, opPing, nil, true)},
+			err:    errBadControl,
+		},
+		{
+			name:   "large control frame",
+			frames: [][]byte{frame(true, opPing, large[:maxControlSize+1], true)},
+			err:    errBadControl,
+		},
+		{
+			name:   "frame too large",
+			frames: [][]byte{frame(true, opBinary, make([]byte, maxMessageSize+1), true)},
+			err:    errTooLarge,
+		},
+		{
+			name: "message too large",
+			frames: [][]byte{
+				frame(false, opBinary, make([]byte, maxMessageSize), true),
+				frame(true, opContinuation, []byte("a"), true),
+			},
+			err: errTooLarge,
+		},
+		{
+			name:   "close",
+			frames: [][]byte{frame(true, opClose, nil, true)},
+			err:    ErrClosed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readFrom(bytes.Join(tt.frames, nil))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		host   string
+		want   bool
+	}{
+		{"", "localhost:8080", true},
+		{"http://localhost:8080", "localhost:8080", true},
+		{"http://LOCALHOST:8080", "localhost:8080", true},
+		{"http://localhost:8081", "localhost:8080", false},
+		{"https://example.com", "localhost:8080", false},
+		{"null", "localhost:8080", false},
+	}
+	for _, tt := range tests {
+		r := &http.Request{Host: tt.host, Header: http.Header{}}
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := sameOrigin(r); got != tt.want {
+			t.Errorf("sameOrigin(%q, %q) = %v, want %v", tt.origin, tt.host, got, tt.want)
+		}
+	}
+}
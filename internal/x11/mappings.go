@@ -28,84 +28,104 @@ var Buttons = map[string]xproto.Button{
 // Keycodes is a list of keycodes used for config parsing.
 var Keycodes = map[string]xproto.Keycode{
 	// Keys
-	"0":            19,
-	"1":            10,
-	"2":            11,
-	"3":            12,
-	"4":            13,
-	"5":            14,
-	"6":            15,
-	"7":            16,
-	"8":            17,
-	"9":            18,
-	"a":            38,
-	"b":            56,
-	"c":            54,
-	"d":            40,
-	"e":            26,
-	"f":            41,
-	"g":            42,
-	"h":            43,
-	"i":            31,
-	"j":            44,
-	"k":            45,
-	"l":            46,
-	"m":            58,
-	"n":            57,
-	"o":            32,
-	"p":            33,
-	"q":            24,
-	"r":            27,
-	"s":            39,
-	"t":            28,
-	"u":            30,
-	"v":            55,
-	"w":            25,
-	"x":            53,
-	"y":            29,
-	"z":            52,
-	"f1":           67,
-	"f2":           68,
-	"f3":           69,
-	"f4":           70,
-	"f5":           71,
-	"f6":           72,
-	"f7":           73,
-	"f8":           74,
-	"f9":           75,
-	"f10":          76,
-	"f11":          95,
-	"f12":          96,
-	"down":         116,
-	"left":         113,
-	"right":        114,
-	"up":           111,
-	"apostrophe":   48,
-	"grave":        49,
-	"backslash":    51,
-	"comma":        59,
-	"equal":        21,
-	"minus":        20,
-	"period":       60,
-	"semicolon":    47,
-	"slash":        61,
-	"space":        65,
-	"tab":          23,
-	"enter":        36,
-	"return":       36,
-	"escape":       9,
-	"esc":          9,
-	"backspace":    22,
-	"delete":       119,
-	"del":          119,
-	"end":          115,
-	"home":         110,
-	"insert":       118,
-	"ins":          118,
-	"pause":        127,
-	"menu":         135,
-	"print.screen": 107,
-	"printscreen":  107,
+	"0":               19,
+	"1":               10,
+	"2":               11,
+	"3":               12,
+	"4":               13,
+	"5":               14,
+	"6":               15,
+	"7":               16,
+	"8":               17,
+	"9":               18,
+	"a":               38,
+	"b":               56,
+	"c":               54,
+	"d":               40,
+	"e":               26,
+	"f":               41,
+	"g":               42,
+	"h":               43,
+	"i":               31,
+	"j":               44,
+	"k":               45,
+	"l":               46,
+	"m":               58,
+	"n":               57,
+	"o":               32,
+	"p":               33,
+	"q":               24,
+	"r":               27,
+	"s":               39,
+	"t":               28,
+	"u":               30,
+	"v":               55,
+	"w":               25,
+	"x":               53,
+	"y":               29,
+	"z":               52,
+	"f1":              67,
+	"f2":              68,
+	"f3":              69,
+	"f4":              70,
+	"f5":              71,
+	"f6":              72,
+	"f7":              73,
+	"f8":              74,
+	"f9":              75,
+	"f10":             76,
+	"f11":             95,
+	"f12":             96,
+	"keypad.0":        90,
+	"keypad.1":        87,
+	"keypad.2":        88,
+	"keypad.3":        89,
+	"keypad.4":        83,
+	"keypad.5":        84,
+	"keypad.6":        85,
+	"keypad.7":        79,
+	"keypad.8":        80,
+	"keypad.9":        81,
+	"keypad.add":      86,
+	"keypad.decimal":  91,
+	"keypad.divide":   106,
+	"keypad.enter":    104,
+	"keypad.equal":    125,
+	"keypad.multiply": 63,
+	"keypad.subtract": 82,
+	"down":            116,
+	"left":            113,
+	"right":           114,
+	"up":              111,
+	"apostrophe":      48,
+	"grave":           49,
+	"grave.accent":    49,
+	"backslash":       51,
+	"comma":           59,
+	"equal":           21,
+	"left.bracket":    34,
+	"minus":           20,
+	"period":          60,
+	"right.bracket":   35,
+	"semicolon":       47,
+	"slash":           61,
+	"space":           65,
+	"tab":             23,
+	"enter":           36,
+	"return":          36,
+	"escape":          9,
+	"esc":             9,
+	"backspace":       22,
+	"delete":          119,
+	"del":             119,
+	"end":             115,
+	"home":            110,
+	"insert":          118,
+	"ins":             118,
+	"pause":           127,
+	"menu":            135,
+	"print.screen":    107,
+	"printscreen":     107,
 }
 
 // KeycodesMc is a list of keycodes used for parsing Minecraft options.
@@ -218,3 +238,120 @@ var Modifiers = map[string]xproto.Keycode{
 	"rctrl":    105,
 	"rcontrol": 105,
 }
+
+// Keysyms is a list of keysyms used for layout-aware config parsing. Every
+// name in Keycodes and Modifiers has an entry here (see TestKeysymsComplete.)
+var Keysyms = map[string]xproto.Keysym{
+	// Keys
+	"0":               0x0030,
+	"1":               0x0031,
+	"2":               0x0032,
+	"3":               0x0033,
+	"4":               0x0034,
+	"5":               0x0035,
+	"6":               0x0036,
+	"7":               0x0037,
+	"8":               0x0038,
+	"9":               0x0039,
+	"a":               0x0061,
+	"b":               0x0062,
+	"c":               0x0063,
+	"d":               0x0064,
+	"e":               0x0065,
+	"f":               0x0066,
+	"g":               0x0067,
+	"h":               0x0068,
+	"i":               0x0069,
+	"j":               0x006a,
+	"k":               0x006b,
+	"l":               0x006c,
+	"m":               0x006d,
+	"n":               0x006e,
+	"o":               0x006f,
+	"p":               0x0070,
+	"q":               0x0071,
+	"r":               0x0072,
+	"s":               0x0073,
+	"t":               0x0074,
+	"u":               0x0075,
+	"v":               0x0076,
+	"w":               0x0077,
+	"x":               0x0078,
+	"y":               0x0079,
+	"z":               0x007a,
+	"f1":              0xffbe,
+	"f2":              0xffbf,
+	"f3":              0xffc0,
+	"f4":              0xffc1,
+	"f5":              0xffc2,
+	"f6":              0xffc3,
+	"f7":              0xffc4,
+	"f8":              0xffc5,
+	"f9":              0xffc6,
+	"f10":             0xffc7,
+	"f11":             0xffc8,
+	"f12":             0xffc9,
+	"keypad.0":        0xffb0,
+	"keypad.1":        0xffb1,
+	"keypad.2":        0xffb2,
+	"keypad.3":        0xffb3,
+	"keypad.4":        0xffb4,
+	"keypad.5":        0xffb5,
+	"keypad.6":        0xffb6,
+	"keypad.7":        0xffb7,
+	"keypad.8":        0xffb8,
+	"keypad.9":        0xffb9,
+	"keypad.add":      0xffab,
+	"keypad.decimal":  0xffae,
+	"keypad.divide":   0xffaf,
+	"keypad.enter":    0xff8d,
+	"keypad.equal":    0xffbd,
+	"keypad.multiply": 0xffaa,
+	"keypad.subtract": 0xffad,
+	"down":            0xff54,
+	"left":            0xff51,
+	"right":           0xff53,
+	"up":              0xff52,
+	"apostrophe":      0x0027,
+	"grave":           0x0060,
+	"grave.accent":    0x0060,
+	"backslash":       0x005c,
+	"comma":           0x002c,
+	"equal":           0x003d,
+	"left.bracket":    0x005b,
+	"minus":           0x002d,
+	"period":          0x002e,
+	"right.bracket":   0x005d,
+	"semicolon":       0x003b,
+	"slash":           0x002f,
+	"space":           0x0020,
+	"tab":             0xff09,
+	"enter":           0xff0d,
+	"return":          0xff0d,
+	"escape":          0xff1b,
+	"esc":             0xff1b,
+	"backspace":       0xff08,
+	"delete":          0xffff,
+	"del":             0xffff,
+	"end":             0xff57,
+	"home":            0xff50,
+	"insert":          0xff63,
+	"ins":             0xff63,
+	"pause":           0xff13,
+	"menu":            0xff67,
+	"print.screen":    0xff61,
+	"printscreen":     0xff61,
+
+	// Modifiers
+	"ctrl":     0xffe3,
+	"control":  0xffe3,
+	"lctrl":    0xffe3,
+	"lcontrol": 0xffe3,
+	"shift":    0xffe1,
+	"lshift":   0xffe1,
+	"rshift":   0xffe2,
+	"alt":      0xffe9,
+	"lalt":     0xffe9,
+	"rctrl":    0xffe4,
+	"rcontrol": 0xffe4,
+}
@@ -0,0 +1,43 @@
+package x11
+
+import (
+	"testing"
+
+	"github.com/jezek/xgb/xproto"
+)
+
+func TestKeysymsComplete(t *testing.T) {
+	tables := []struct {
+		name  string
+		names map[string]xproto.Keycode
+	}{
+		{"Keycodes", Keycodes},
+		{"Modifiers", Modifiers},
+	}
+	for _, table := range tables {
+		for name := range table.names {
+			if _, ok := Keysyms[name]; !ok {
+				t.Errorf("%s name %q has no keysym", table.name, name)
+			}
+		}
+	}
+	for name := range Keysyms {
+		_, key := Keycodes[name]
+		_, mod := Modifiers[name]
+		if !key && !mod {
+			t.Errorf("keysym name %q is not a key or modifier", name)
+		}
+	}
+}
+
+func TestKeysymsMatchKeycodes(t *testing.T) {
+	// Names which refer to the same key must have the same keysym.
+	syms := make(map[xproto.Keycode]xproto.Keysym)
+	for name, code := range Keycodes {
+		sym := Keysyms[name]
+		if other, ok := syms[code]; ok && other != sym {
+			t.Errorf("key %q (keycode %d) has keysym %#x, want %#x", name, code, sym, other)
+		}
+		syms[code] = sym
+	}
+}
@@ -11,22 +11,26 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jezek/xgb"
 	"github.com/jezek/xgb/xproto"
+	"golang.org/x/exp/slices"
 )
 
 // Atom names
 const (
-	netActiveWindow   = "_NET_ACTIVE_WINDOW"
-	netCurrentDesktop = "_NET_CURRENT_DESKTOP"
-	netWmDesktop      = "_NET_WM_DESKTOP"
-	netWmPid          = "_NET_WM_PID"
-	netWmName         = "_NET_WM_NAME"
-	utf8String        = "UTF8_STRING"
-	wmClass           = "WM_CLASS"
-	wmName            = "WM_NAME"
+	netActiveWindow       = "_NET_ACTIVE_WINDOW"
+	netCurrentDesktop     = "_NET_CURRENT_DESKTOP"
+	netSupportingWmCheck  = "_NET_SUPPORTING_WM_CHECK"
+	netWmBypassCompositor = "_NET_WM_BYPASS_COMPOSITOR"
+	netWmDesktop          = "_NET_WM_DESKTOP"
+	netWmPid              = "_NET_WM_PID"
+	netWmName             = "_NET_WM_NAME"
+	utf8String            = "UTF8_STRING"
+	wmClass               = "WM_CLASS"
+	wmName                = "WM_NAME"
 )
 
 // Key/button states
@@ -61,6 +65,12 @@ const (
 		xproto.ConfigWindowWidth
 )
 
+// The number of samples to take when measuring the time offset.
+const offsetSamples = 10
+
+// The keysym of the NumLock key.
+const keysymNumLock xproto.Keysym = 0xff7f
+
 // Important keys
 var (
 	KeyEsc   = xproto.Keycode(9)
@@ -106,13 +116,19 @@ type Client struct {
 	active xproto.Window
 
 	// The offset between the system clock and X server time, in milliseconds.
+	// It is accessed atomically, since it can be recalibrated at any time.
 	timeOffset uint64
 
 	// Information about the last key events sent for each window. This is used
 	// to ensure that resetti's inputs don't get dropped by GLFW.
 	lastKeyState map[xproto.Window]keyState
 
-	// The mutex guards lastKeyState and active.
+	// Counters for the key events sent, and how many of them had their
+	// timestamps moved ahead to avoid being dropped by GLFW.
+	keyEvents    uint64
+	adjustedKeys uint64
+
+	// The mutex guards lastKeyState, active, and the key event counters.
 	mu sync.Mutex
 }
 
@@ -125,6 +141,19 @@ type FocusEvent xproto.Window
 // InputState represents the state of a button or key (up or down.)
 type InputState int
 
+// KeyStats contains statistics about the key events sent by a Client.
+type KeyStats struct {
+	Sent     uint64 // Number of key events sent
+	Adjusted uint64 // Number of key events with adjusted timestamps
+}
+
+// KeyBatch is a sequence of key events to be sent to a single window with
+// consecutive timestamps.
+type KeyBatch struct {
+	win    xproto.Window
+	events []batchEvent
+}
+
 // Keymap contains information about the state of the user's keyboard.
 type Keymap struct {
 	// Keyboard data. 256-bit bitfield.
@@ -149,6 +178,19 @@ type atomCache struct {
 	data map[string]xproto.Atom
 }
 
+// offsetSample is a single measurement of the offset between the system clock
+// and X server time.
+type offsetSample struct {
+	offset uint64 // Offset in milliseconds
+	rtt    int64  // Round trip time of the measurement in milliseconds
+}
+
+// batchEvent is a single key event within a KeyBatch.
+type batchEvent struct {
+	code  xproto.Keycode
+	state InputState
+}
+
 // keyState contains state about the last key event sent to a given window.
 // This is used to ensure that resetti's inputs don't get dropped by GLFW.
 type keyState struct {
@@ -161,6 +203,11 @@ type rawEvent interface {
 	Bytes() []byte
 }
 
+// NewKeyBatch creates a new, empty KeyBatch for the given window.
+func NewKeyBatch(win xproto.Window) *KeyBatch {
+	return &KeyBatch{win: win}
+}
+
 // NewClient attempts to create a new Client.
 func NewClient() (Client, error) {
 	conn, err := xgb.NewConn()
@@ -191,6 +238,8 @@ func NewClient() (Client, error) {
 		0,
 		offset,
 		make(map[xproto.Window]keyState),
+		0,
+		0,
 		sync.Mutex{},
 	}, nil
 }
@@ -281,7 +330,101 @@ func (c *Client) GetActiveWindow() xproto.Window {
 
 // GetCurrentTime returns the approximate current X server time.
 func (c *Client) GetCurrentTime() uint32 {
-	return uint32(time.Now().UnixMilli() - int64(c.timeOffset))
+	return uint32(time.Now().UnixMilli() - int64(atomic.LoadUint64(&c.timeOffset)))
+}
+
+// GetTimeOffset returns the offset between the system clock and X server time
+// (in milliseconds) which is used for the timestamps of key events.
+func (c *Client) GetTimeOffset() uint64 {
+	return atomic.LoadUint64(&c.timeOffset)
+}
+
+// GetKeyStats returns statistics about the key events sent so far.
+func (c *Client) GetKeyStats() KeyStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return KeyStats{c.keyEvents, c.adjustedKeys}
+}
+
+// GetKeysymMapping returns a mapping of keysyms to the keycodes which produce
+// them in the current keyboard layout. If several keycodes produce the same
+// keysym, unshifted keys are preferred, followed by the lowest keycode.
+func (c *Client) GetKeysymMapping() (map[xproto.Keysym]xproto.Keycode, error) {
+	setup := xproto.Setup(c.conn)
+	count := byte(setup.MaxKeycode - setup.MinKeycode + 1)
+	reply, err := xproto.GetKeyboardMapping(c.conn, setup.MinKeycode, count).Reply()
+	if err != nil {
+		return nil, err
+	}
+	perCode := int(reply.KeysymsPerKeycode)
+	mapping := make(map[xproto.Keysym]xproto.Keycode)
+	for column := 0; column < perCode; column += 1 {
+		for i := 0; i < int(count); i += 1 {
+			sym := reply.Keysyms[i*perCode+column]
+			if sym == 0 {
+				continue
+			}
+			if _, ok := mapping[sym]; !ok {
+				mapping[sym] = setup.MinKeycode + xproto.Keycode(i)
+			}
+		}
+	}
+	return mapping, nil
+}
+
+// IsKeyGrabbed returns whether or not the given key combination is grabbed by
+// another client (e.g. the window manager.) Since a grab only applies to the
+// exact set of modifiers it was made with, the combination is also checked
+// with CapsLock and NumLock enabled, which some programs grab separately. A
+// grab made with AnyModifier is detected by any of these checks.
+func (c *Client) IsKeyGrabbed(key xproto.Keycode, mods []xproto.Keycode) (bool, error) {
+	modmap, err := xproto.GetModifierMapping(c.conn).Reply()
+	if err != nil {
+		return false, fmt.Errorf("get modifier mapping: %w", err)
+	}
+	keysyms, err := c.GetKeysymMapping()
+	if err != nil {
+		return false, fmt.Errorf("get keyboard mapping: %w", err)
+	}
+	var mask uint16
+	numLock := uint16(xproto.ModMask2)
+	perMod := int(modmap.KeycodesPerModifier)
+	for idx, code := range modmap.Keycodes {
+		if code != 0 && code == keysyms[keysymNumLock] {
+			numLock = 1 << (idx / perMod)
+		}
+		if slices.Contains(mods, code) {
+			mask |= 1 << (idx / perMod)
+		}
+	}
+	for _, locks := range []uint16{0, xproto.ModMaskLock, numLock, xproto.ModMaskLock | numLock} {
+		grabbed, err := c.tryGrabKey(key, mask|locks)
+		if err != nil || grabbed {
+			return grabbed, err
+		}
+	}
+	return false, nil
+}
+
+// tryGrabKey returns whether the given key and modifier mask are grabbed by
+// another client by attempting to grab (and then release) them.
+func (c *Client) tryGrabKey(key xproto.Keycode, mask uint16) (bool, error) {
+	err := xproto.GrabKeyChecked(
+		c.conn,
+		false,
+		c.root,
+		mask,
+		key,
+		xproto.GrabModeAsync,
+		xproto.GrabModeAsync,
+	).Check()
+	if err != nil {
+		if _, ok := err.(xproto.AccessError); ok {
+			return true, nil
+		}
+		return false, err
+	}
+	return false, xproto.UngrabKeyChecked(c.conn, key, c.root, mask).Check()
 }
 
 // GetRootWindow returns the ID of the root window.
@@ -289,6 +432,16 @@ func (c *Client) GetRootWindow() xproto.Window {
 	return c.root
 }
 
+// GetWmName returns the name of the running window manager, as reported by
+// the window manager itself.
+func (c *Client) GetWmName() (string, error) {
+	win, err := c.getPropertyInt(c.root, netSupportingWmCheck, xproto.AtomWindow)
+	if err != nil {
+		return "", fmt.Errorf("get supporting wm check: %w", err)
+	}
+	return c.GetWindowTitle(xproto.Window(win))
+}
+
 // GetWindowList returns a list of all open windows.
 func (c *Client) GetWindowList() []xproto.Window {
 	return c.GetWindowChildren(c.root)
@@ -386,6 +539,48 @@ func (c *Client) Poll(ctx context.Context) (<-chan Event, <-chan error, error) {
 	return ch, errch, nil
 }
 
+// SetTimeOffset changes the offset between the system clock and X server time
+// (in milliseconds) which is used for the timestamps of key events, such as
+// after a new offset was measured with MeasureTimeOffset.
+func (c *Client) SetTimeOffset(offset uint64) {
+	atomic.StoreUint64(&c.timeOffset, offset)
+}
+
+// MeasureLatency measures the round trip time of a request to the X server.
+func (c *Client) MeasureLatency() (time.Duration, error) {
+	start := time.Now()
+	if _, err := xproto.GetInputFocus(c.conn).Reply(); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// MeasureTimeOffset approximates the current offset between the system clock
+// and X server time (in milliseconds) without changing the offset used for
+// key events. A separate connection is used, since the events needed for the
+// measurement would otherwise be consumed by Poll.
+func (c *Client) MeasureTimeOffset() (uint64, error) {
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	err = xproto.ChangeWindowAttributesChecked(
+		conn,
+		c.root,
+		xproto.CwEventMask,
+		[]uint32{xproto.EventMaskPropertyChange},
+	).Check()
+	if err != nil {
+		return 0, err
+	}
+	samples, err := sampleOffset(conn)
+	if err != nil {
+		return 0, err
+	}
+	return filterOffset(samples), nil
+}
+
 // QueryKeymap queries the state of the keyboard.
 func (c *Client) QueryKeymap() (Keymap, error) {
 	reply, err := xproto.QueryKeymap(c.conn).Reply()
@@ -413,6 +608,22 @@ func (c *Client) QueryPointer(win xproto.Window) (Pointer, error) {
 	return p, nil
 }
 
+// SendKeyBatch sends all of the key events in the given batch. The timestamps
+// for every event are computed at once, so that no other key events sent by
+// resetti can be interleaved with the batch. Each event is still sent as its
+// own (unchecked) SendEvent request.
+func (c *Client) SendKeyBatch(batch *KeyBatch) {
+	times := make([]uint32, len(batch.events))
+	c.mu.Lock()
+	for i, evt := range batch.events {
+		times[i] = c.nextKeyTime(evt.code, batch.win)
+	}
+	c.mu.Unlock()
+	for i, evt := range batch.events {
+		c.sendKeyEventAt(evt.code, evt.state, batch.win, times[i])
+	}
+}
+
 // SendKeyDown sends a key down event to the given window with the given key.
 func (c *Client) SendKeyDown(code xproto.Keycode, win xproto.Window) {
 	c.sendKeyEvent(code, StateDown, win)
@@ -430,6 +641,30 @@ func (c *Client) SendKeyUp(code xproto.Keycode, win xproto.Window) {
 	c.sendKeyEvent(code, StateUp, win)
 }
 
+// SetBypassCompositor asks the compositor to stop compositing (unredirect) the
+// given window, or removes the request.
+func (c *Client) SetBypassCompositor(win xproto.Window, bypass bool) error {
+	atom, err := c.atoms.Get(netWmBypassCompositor)
+	if err != nil {
+		return fmt.Errorf("get _NET_WM_BYPASS_COMPOSITOR atom: %w", err)
+	}
+	// 1 requests that compositing be disabled, 0 means no preference.
+	data := make([]byte, 4)
+	if bypass {
+		binary.LittleEndian.PutUint32(data, 1)
+	}
+	return xproto.ChangePropertyChecked(
+		c.conn,
+		xproto.PropModeReplace,
+		win,
+		atom,
+		xproto.AtomCardinal,
+		32,
+		1,
+		data,
+	).Check()
+}
+
 // UngrabPointer ungrabs the mouse pointer.
 func (c *Client) UngrabPointer() error {
 	return xproto.UngrabPointerChecked(c.conn, xproto.TimeCurrentTime).Check()
@@ -542,8 +777,17 @@ func (c *Client) sendKeyEvent(key xproto.Keycode, state InputState, win xproto.W
 	// https://github.com/glfw/glfw/blob/3.3.8/src/x11_window.c#L1359
 
 	c.mu.Lock()
+	time := c.nextKeyTime(key, win)
+	c.mu.Unlock()
+	c.sendKeyEventAt(key, state, win, time)
+}
+
+// nextKeyTime returns the timestamp to use for the next key event sent to the
+// given window and records it. The caller must hold the mutex.
+func (c *Client) nextKeyTime(key xproto.Keycode, win xproto.Window) uint32 {
 	lastState, ok := c.lastKeyState[win]
-	time := c.GetCurrentTime() + 15
+	current := c.GetCurrentTime() + 15
+	time := current
 	if ok {
 		if lastState.time >= time {
 			time = lastState.time + 1
@@ -552,9 +796,17 @@ func (c *Client) sendKeyEvent(key xproto.Keycode, state InputState, win xproto.W
 			time = lastState.time + 20
 		}
 	}
+	c.keyEvents += 1
+	if time != current {
+		c.adjustedKeys += 1
+	}
 	c.lastKeyState[win] = keyState{time, key}
-	c.mu.Unlock()
+	return time
+}
 
+// sendKeyEventAt sends a key event with the given timestamp to the given
+// window.
+func (c *Client) sendKeyEventAt(key xproto.Keycode, state InputState, win xproto.Window, time uint32) {
 	evt := xproto.KeyPressEvent{
 		Detail:     key,
 		Time:       xproto.Timestamp(time),
@@ -632,6 +884,34 @@ func (c *Client) poll(ctx context.Context, ch chan<- Event, errch chan<- error)
 	}
 }
 
+// KeyDown adds a key down event to the batch.
+func (b *KeyBatch) KeyDown(code xproto.Keycode) {
+	b.events = append(b.events, batchEvent{code, StateDown})
+}
+
+// KeyPress adds a key press (key down and key up event) to the batch.
+func (b *KeyBatch) KeyPress(code xproto.Keycode) {
+	b.events = append(b.events, batchEvent{code, StateDown}, batchEvent{code, StateUp})
+}
+
+// KeyUp adds a key up event to the batch.
+func (b *KeyBatch) KeyUp(code xproto.Keycode) {
+	b.events = append(b.events, batchEvent{code, StateUp})
+}
+
+// Pressed returns the keycodes of all pressed keys in the keymap.
+func (k *Keymap) Pressed() []xproto.Keycode {
+	var pressed []xproto.Keycode
+	for i, v := range k.data {
+		for bit := 0; bit < 8; bit += 1 {
+			if v&(1<<bit) != 0 {
+				pressed = append(pressed, xproto.Keycode(i*8+bit))
+			}
+		}
+	}
+	return pressed
+}
+
 // HasPressed determines whether all of the given keys are pressed in the
 // keymap.
 func (k *Keymap) HasPressed(mask [32]byte) bool {
@@ -652,16 +932,50 @@ func (p *Pointer) HasPressed(button xproto.Button) bool {
 // approximateOffset attempts to find the offset between the system clock and
 // the X server time.
 func approximateOffset(c *xgb.Conn) (uint64, error) {
+	samples, err := sampleOffset(c)
+	if err != nil {
+		return 0, err
+	}
+
+	// Take the average of every sample.
+	offsetSum := uint64(0)
+	for _, sample := range samples {
+		offsetSum += sample.offset
+	}
+	return offsetSum / uint64(len(samples)), nil
+}
+
+// filterOffset returns the average offset of the samples with the shortest
+// round trips, discarding the slower half. A slow reply (e.g. because the X
+// server or resetti was not scheduled in time) makes the offset look larger
+// than it is, which would otherwise be mistaken for drift. The offsets are
+// computed the same way as in approximateOffset, so that they can be compared
+// with the offset found at startup.
+func filterOffset(samples []offsetSample) uint64 {
+	sorted := slices.Clone(samples)
+	slices.SortStableFunc(sorted, func(a, b offsetSample) bool {
+		return a.rtt < b.rtt
+	})
+	sorted = sorted[:(len(sorted)+1)/2]
+	offsetSum := uint64(0)
+	for _, sample := range sorted {
+		offsetSum += sample.offset
+	}
+	return offsetSum / uint64(len(sorted))
+}
+
+// sampleOffset measures the offset between the system clock and the X server
+// time several times.
+func sampleOffset(c *xgb.Conn) ([]offsetSample, error) {
 	reply, err := xproto.InternAtom(c, false, uint16(len(wmName)), wmName).Reply()
 	if err != nil {
-		return 0, fmt.Errorf("get WM_NAME atom: %w", err)
+		return nil, fmt.Errorf("get WM_NAME atom: %w", err)
 	}
 	atom := reply.Atom
 
-	// Try to get the time offset 10 times and take the average.
-	offsetSum := uint64(0)
+	samples := make([]offsetSample, 0, offsetSamples)
 	root := xproto.Setup(c).DefaultScreen(c).Root
-	for i := 0; i < 10; i += 1 {
+	for i := 0; i < offsetSamples; i += 1 {
 		// Send a no-op property change request and take note of the timestamp
 		// sent back by the X server. This method is recommended by the ICCCM
 		// spec:
@@ -677,17 +991,32 @@ func approximateOffset(c *xgb.Conn) (uint64, error) {
 			0,
 			[]byte{},
 		)
+		evt, err := waitForPropertyChange(c, root, atom)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, offsetSample{
+			offset: uint64(send - int64(evt.Time)),
+			rtt:    time.Now().UnixMilli() - send,
+		})
+	}
+	return samples, nil
+}
+
+// waitForPropertyChange waits for the given property of the given window to
+// change. Any other events (e.g. property changes made by the window manager)
+// are skipped.
+func waitForPropertyChange(c *xgb.Conn, win xproto.Window, atom xproto.Atom) (xproto.PropertyNotifyEvent, error) {
+	for {
 		rawEvt, err := c.WaitForEvent()
 		if rawEvt == nil && err == nil {
-			return 0, ErrConnectionDied
+			return xproto.PropertyNotifyEvent{}, ErrConnectionDied
 		} else if err != nil {
-			return 0, fmt.Errorf("receive response: %w", err)
+			return xproto.PropertyNotifyEvent{}, fmt.Errorf("receive response: %w", err)
 		}
 		evt, ok := rawEvt.(xproto.PropertyNotifyEvent)
-		if !ok {
-			return 0, fmt.Errorf("invalid event type (%T)", rawEvt)
+		if ok && evt.Window == win && evt.Atom == atom {
+			return evt, nil
 		}
-		offsetSum += uint64(send - int64(evt.Time))
 	}
-	return offsetSum / 10, nil
 }
@@ -0,0 +1,41 @@
+package x11
+
+import "testing"
+
+func TestFilterOffset(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []offsetSample
+		want    uint64
+	}{
+		{
+			name:    "single",
+			samples: []offsetSample{{1000, 1}},
+			want:    1000,
+		},
+		{
+			name:    "equal round trips",
+			samples: []offsetSample{{1000, 1}, {1002, 1}, {1004, 1}, {1006, 1}},
+			want:    1001,
+		},
+		{
+			name: "slow replies",
+			samples: []offsetSample{
+				{1000, 1}, {1040, 40}, {1002, 1}, {1090, 90},
+				{1001, 2}, {1003, 1}, {1060, 60}, {1001, 1},
+				{1020, 20}, {1002, 2},
+			},
+			want: 1001,
+		},
+		{
+			name:    "odd count",
+			samples: []offsetSample{{1050, 50}, {1000, 1}, {1010, 3}},
+			want:    1005,
+		},
+	}
+	for _, tt := range tests {
+		if got := filterOffset(tt.samples); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
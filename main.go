@@ -18,6 +18,15 @@ var notice string
 //go:embed .version
 var version string
 
+// Subcommands which log to stderr rather than resetti's log file.
+var detachedCommands = map[string]bool{
+	"stats":         true,
+	"check":         true,
+	"keys":          true,
+	"debug-dump":    true,
+	"config-schema": true,
+}
+
 func main() {
 	// Setup logger output.
 	logPath, ok := os.LookupEnv("RESETTI_LOG_PATH")
@@ -25,11 +34,20 @@ func main() {
 		logPath = "/tmp/resetti.log"
 	}
 
-	logger := log.DefaultLogger(log.INFO, logPath, false)
-	logger.Info("Started Logger")
-	defer func() {
-		logger.Close()
-	}()
+	// Subcommands which only inspect resetti's state must not take over the
+	// log (and log conf file) of a session which may be running, so they log
+	// to stderr instead.
+	var logger log.Logger
+	if len(os.Args) > 1 && detachedCommands[os.Args[1]] {
+		log.Detach()
+		logger = log.Rebuild()
+	} else {
+		logger = log.DefaultLogger(log.INFO, logPath, false)
+		logger.Info("Started Logger")
+		defer func() {
+			logger.Close()
+		}()
+	}
 
 	if err := res.WriteResources(); err != nil {
 		logger.Error("Failed to write resources: %s", err)
@@ -61,6 +79,51 @@ func main() {
 		} else {
 			logger.Info("Created profile!")
 		}
+	case "stats":
+		if err := ctl.PrintStats(os.Stdout); err != nil {
+			logger.Error("Failed to print stats: %s", err)
+		}
+		if err := ctl.PrintLedger(os.Stdout); err != nil {
+			logger.Error("Failed to print reset ledger: %s", err)
+		}
+	case "check":
+		if len(os.Args) < 3 {
+			printHelp()
+			os.Exit(1)
+		}
+		if !ctl.CheckProfile(os.Stdout, os.Args[2]) {
+			os.Exit(1)
+		}
+	case "keys":
+		var profile string
+		if len(os.Args) > 2 {
+			profile = os.Args[2]
+		}
+		if err := ctl.CaptureKeys(os.Stdout, profile); err != nil {
+			logger.Error("Failed to capture keys: %s", err)
+			os.Exit(1)
+		}
+	case "config-schema":
+		schema, err := cfg.Schema()
+		if err != nil {
+			logger.Error("Failed to generate config schema: %s", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(schema))
+	case "debug-dump":
+		if len(os.Args) < 3 {
+			printHelp()
+			os.Exit(1)
+		}
+		path := "resetti-debug.tar.gz"
+		if len(os.Args) > 3 {
+			path = os.Args[3]
+		}
+		if err := ctl.DebugDump(path, os.Args[2], logPath, version); err != nil {
+			logger.Error("Failed to make debug dump: %s", err)
+		} else {
+			logger.Info("Wrote debug dump to %s", path)
+		}
 	case "-d", "--debug":
 		logger.Info("Running in debug mode.")
 		logger.SetLevel(log.DEBUG)
@@ -70,32 +133,98 @@ func main() {
 			os.Exit(1)
 		}
 		profileName := os.Args[2]
-		Run(profileName)
+		opts, _ := parseRunArgs(&logger, os.Args[3:])
+		Run(profileName, opts, true)
 	default:
-		if len(os.Args) >= 3 {
-			if os.Args[2] == "-d" || os.Args[2] == "--debug" {
-				logger.Info("Running in debug mode.")
-				logger.SetLevel(log.DEBUG)
+		opts, debug := parseRunArgs(&logger, os.Args[2:])
+		profileName := os.Args[1]
+		Run(profileName, opts, debug)
+	}
+}
+
+// parseRunArgs parses the options given after the profile name when running
+// resetti. It returns the options for the controller and whether debug mode
+// was requested.
+func parseRunArgs(logger *log.Logger, args []string) (ctl.Options, bool) {
+	opts := ctl.Options{}
+	debug := false
+	for len(args) > 0 {
+		switch args[0] {
+		case "-d", "--debug":
+			logger.Info("Running in debug mode.")
+			logger.SetLevel(log.DEBUG)
+			debug = true
+		case "--record", "--replay":
+			if len(args) < 2 {
+				logger.Error("Expected file name after %s.", args[0])
+				printHelp()
+				os.Exit(1)
+			}
+			if args[0] == "--record" {
+				opts.RecordPath = args[1]
+			} else {
+				opts.ReplayPath = args[1]
 			}
+			args = args[1:]
 		}
-		profileName := os.Args[1]
-		Run(profileName)
+		args = args[1:]
 	}
+	return opts, debug
 }
 
-func Run(profileName string) {
+func Run(profileName string, opts ctl.Options, debug bool) {
 	// Get configuration and run.
 	profile, err := cfg.GetProfile(profileName)
 	if err != nil {
 		log.Error("Failed to get profile: %s", err)
 		return
 	}
-	if err = ctl.Run(&profile); err != nil {
+	if err = configureLog(&profile.Log, debug); err != nil {
+		log.Error("Failed to configure log: %s", err)
+		return
+	}
+	if err = ctl.Run(&profile, opts); err != nil {
 		log.Error("Failed to run: %s", err)
 		return
 	}
 }
 
+// configureLog applies the log settings from the user's profile. The level is
+// not changed if resetti is running in debug mode.
+func configureLog(conf *cfg.Log, debug bool) error {
+	if err := log.Configure(conf.Format, int64(conf.MaxSize)<<20, conf.Backups); err != nil {
+		return err
+	}
+	if debug {
+		return nil
+	}
+	logConf, err := log.ConfRead()
+	if err != nil {
+		return err
+	}
+	if len(conf.Modules) > 0 {
+		modules := make(map[string]log.LogLevel)
+		for module, name := range conf.Modules {
+			level, err := log.ParseLevel(name)
+			if err != nil {
+				return err
+			}
+			modules[module] = level
+		}
+		if err = logConf.UpdateModules(modules); err != nil {
+			return err
+		}
+	}
+	if conf.Level == "" {
+		return nil
+	}
+	level, err := log.ParseLevel(conf.Level)
+	if err != nil {
+		return err
+	}
+	return logConf.UpdateLevel(level)
+}
+
 func printHelp() {
 	fmt.Println(`
     resetti - Minecraft resetting macro
@@ -104,10 +233,22 @@ func printHelp() {
           --force-log           Force the latest.log reader to be used.
           --force-wpstate       Force the wpstateout.txt reader to be used.
           -d, --debug           Run resetti in debug mode.
+          --record [FILE]       Record all keybind presses to FILE.
+          --replay [FILE]       Replay keybind presses recorded in FILE.
 
     SUBCOMMANDS:
         resetti new [PROFILE]   Create a new profile named PROFILE with
                                 the default configuration.
+        resetti stats           Print statistics from previous sessions.
+        resetti check [PROFILE] Check that PROFILE will work without
+                                starting resetti.
+        resetti keys [PROFILE]  Print the keybind for each key combination
+                                you press, using PROFILE's layout_binds
+                                setting if given.
+        resetti debug-dump [PROFILE] [FILE]
+                                Write information for bug reports to FILE
+                                (resetti-debug.tar.gz by default.)
+        resetti config-schema   Print a JSON schema for profiles.
         resetti help            Print this message.
         resetti version         Get the version of resetti installed.
     `)